@@ -0,0 +1,160 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrInvalidAlias = errors.New("invalid domain alias")
+
+// AliasMap folds alias domains into their canonical domain before counting, such as
+// `googlemail.com` into `gmail.com`, or the domains of acquired brands into the current one.
+// It is safe for concurrent reads, but not for concurrent calls to Add
+type AliasMap struct {
+	aliases map[string]string
+	// prefixes maps the first label of `name.*` patterns to their canonical domain
+	prefixes map[string]string
+}
+
+// NewAliasMap creates an empty AliasMap
+func NewAliasMap() *AliasMap {
+	return &AliasMap{
+		aliases:  map[string]string{},
+		prefixes: map[string]string{},
+	}
+}
+
+// LoadAliasMap parses an AliasMap from `r`, either as a JSON object mapping each alias to
+// its canonical domain, or as CSV data with `alias,canonical` rows (and an optional header
+// with those names). Lines starting with `#` are ignored in CSV data
+func LoadAliasMap(r io.Reader) (*AliasMap, error) {
+	br := bufio.NewReader(r)
+
+	m := NewAliasMap()
+	if isJSONObject(br) {
+		var aliases map[string]string
+		if err := json.NewDecoder(br).Decode(&aliases); err != nil {
+			return nil, err
+		}
+
+		for alias, canonical := range aliases {
+			if err := m.Add(alias, canonical); err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = 2
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	for n := 0; ; n++ {
+		record, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return m, nil
+			}
+			return nil, err
+		}
+
+		if n == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "alias") &&
+			strings.EqualFold(strings.TrimSpace(record[1]), "canonical") {
+			continue
+		}
+
+		if err = m.Add(record[0], record[1]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isJSONObject reports whether the next non-whitespace byte in `br` opens a JSON object
+func isJSONObject(br *bufio.Reader) bool {
+	for size := 64; ; size *= 2 {
+		buf, err := br.Peek(size)
+		if trimmed := bytes.TrimLeft(buf, " \t\r\n\uFEFF"); len(trimmed) > 0 {
+			return trimmed[0] == '{'
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// Add folds the domain `alias` into `canonical`. An alias in the form `name.*` matches
+// any domain whose first label is `name`, such as `hotmail.*` for `hotmail.co.uk`; exact
+// aliases take precedence over these. Each alias is resolved once, so canonical domains
+// are not looked up as aliases themselves
+func (m *AliasMap) Add(alias, canonical string) error {
+	alias = canonicalDomain(alias)
+	canonical = canonicalDomain(canonical)
+
+	switch {
+	case alias == "" || canonical == "":
+		return fmt.Errorf("%w: empty domain in %q -> %q", ErrInvalidAlias, alias, canonical)
+	case alias == canonical:
+		return fmt.Errorf("%w: %q is an alias of itself", ErrInvalidAlias, alias)
+	case strings.HasSuffix(alias, ".*"):
+		prefix := strings.TrimSuffix(alias, ".*")
+		if prefix == "" || strings.ContainsAny(prefix, ".*") {
+			return fmt.Errorf("%w: unsupported pattern %q", ErrInvalidAlias, alias)
+		}
+		m.prefixes[prefix] = canonical
+	case strings.Contains(alias, "*"):
+		return fmt.Errorf("%w: unsupported pattern %q", ErrInvalidAlias, alias)
+	default:
+		m.aliases[alias] = canonical
+	}
+
+	return nil
+}
+
+// Len returns the number of aliases in the AliasMap, including `name.*` patterns
+func (m *AliasMap) Len() int {
+	return len(m.aliases) + len(m.prefixes)
+}
+
+// Canonical returns the canonical domain for `domain`, and whether it is an alias. The
+// domain is expected in lowercase, as counted by the Parser; internationalized domains are
+// looked up in their ASCII form, regardless of the IDNAForm
+func (m *AliasMap) Canonical(domain string) (string, bool) {
+	key := domain
+	if !isASCII(key) {
+		if ascii, err := ToASCII(key); err == nil {
+			key = ascii
+		}
+	}
+
+	if canonical, ok := m.aliases[key]; ok {
+		return canonical, true
+	}
+
+	if len(m.prefixes) > 0 {
+		if idx := strings.IndexByte(key, '.'); idx > 0 {
+			if canonical, ok := m.prefixes[key[:idx]]; ok && canonical != key {
+				return canonical, true
+			}
+		}
+	}
+
+	return domain, false
+}
+
+// canonicalDomain returns the trimmed, lowercase ASCII form of `domain`, as counted by the Parser
+func canonicalDomain(domain string) string {
+	domain = strings.TrimRight(strings.TrimSpace(domain), ".")
+	if ascii, err := ToASCII(domain); err == nil {
+		return ascii
+	}
+
+	return strings.ToLower(domain)
+}
@@ -0,0 +1,162 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestAliasMap(t *testing.T) {
+	for _, testcase := range []struct {
+		name  string
+		input string
+		len   int
+		err   error
+	}{
+		{
+			name:  "CSV",
+			input: "alias,canonical\n# providers\ngooglemail.com,gmail.com\nhotmail.*, outlook.com\nOldBrand.example.,newbrand.example\n",
+			len:   3,
+		},
+		{
+			name:  "CSVWithoutHeader",
+			input: "googlemail.com,gmail.com\nhotmail.*,outlook.com\noldbrand.example,newbrand.example\n",
+			len:   3,
+		},
+		{
+			name:  "JSON",
+			input: "\n  {\"googlemail.com\": \"gmail.com\", \"hotmail.*\": \"outlook.com\", \"oldbrand.example\": \"newbrand.example\"}",
+			len:   3,
+		},
+		{
+			name:  "FailSelfAlias",
+			input: "gmail.com,Gmail.com\n",
+			err:   ErrInvalidAlias,
+		},
+		{
+			name:  "FailEmpty",
+			input: "gmail.com,\n",
+			err:   ErrInvalidAlias,
+		},
+		{
+			name:  "FailPattern",
+			input: `{"*.hotmail.com": "outlook.com"}`,
+			err:   ErrInvalidAlias,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			m, err := LoadAliasMap(strings.NewReader(testcase.input))
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if m.Len() != testcase.len {
+				t.Errorf("alias count mismatch: wanted %d ; got %d", testcase.len, m.Len())
+			}
+
+			for domain, wants := range map[string]string{
+				"googlemail.com":   "gmail.com",
+				"hotmail.co.uk":    "outlook.com",
+				"hotmail.fr":       "outlook.com",
+				"oldbrand.example": "newbrand.example",
+				"outlook.com":      "outlook.com",
+				"mail.hotmail.com": "mail.hotmail.com",
+			} {
+				if canonical, _ := m.Canonical(domain); canonical != wants {
+					t.Errorf("canonical domain mismatch for %q: wanted %q ; got %q", domain, wants, canonical)
+				}
+			}
+		})
+	}
+}
+
+func TestAliases(t *testing.T) {
+	const input = `email
+a@gmail.com
+b@googlemail.com
+c@googlemail.com
+d@hotmail.co.uk
+e@outlook.com
+f@hotmail.fr
+g@hotmail.co.uk
+`
+
+	m := NewAliasMap()
+	if err := m.Add("googlemail.com", "gmail.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if err := m.Add("hotmail.*", "outlook.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	res, err := CountReader(strings.NewReader(input), WithAliases(m), WithSort(SortByCountDesc))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	wants := []Entry{
+		{Count: 4, Domain: "outlook.com", Aliases: []Entry{
+			{Count: 2, Domain: "hotmail.co.uk"},
+			{Count: 1, Domain: "hotmail.fr"},
+		}},
+		{Count: 3, Domain: "gmail.com", Aliases: []Entry{
+			{Count: 2, Domain: "googlemail.com"},
+		}},
+	}
+
+	if len(res.Entries) != len(wants) {
+		t.Errorf("output length mismatch error: wanted %d ; got %d: %v", len(wants), len(res.Entries), res.Entries)
+		return
+	}
+	for idx := range wants {
+		e := res.Entries[idx]
+		if e.Domain != wants[idx].Domain || e.Count != wants[idx].Count || len(e.Aliases) != len(wants[idx].Aliases) {
+			t.Errorf("output mismatch error on index %d: wanted %v ; got %v", idx, wants[idx], e)
+			continue
+		}
+		for i, alias := range wants[idx].Aliases {
+			if e.Aliases[i].Domain != alias.Domain || e.Aliases[i].Count != alias.Count {
+				t.Errorf("alias mismatch error on index %d: wanted %v ; got %v", idx, alias, e.Aliases[i])
+			}
+		}
+	}
+
+	if records := res.Records(); len(records[0].Aliases) != 2 {
+		t.Errorf("expected the records to include their aliases; got %v", records[0])
+	}
+
+	t.Run("Unicode", func(t *testing.T) {
+		m := NewAliasMap()
+		if err := m.Add("bücher.de", "buecher.de"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		// with the default IDNA form, the domain is counted as written
+		res, err := CountReader(strings.NewReader("email\na@bücher.de\nb@buecher.de\nc@bücher.de\n"), WithAliases(m))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if len(res.Entries) != 1 || res.Entries[0].Domain != "buecher.de" || res.Entries[0].Count != 3 {
+			t.Errorf("output mismatch error: wanted buecher.de with 3 rows ; got %v", res.Entries)
+			return
+		}
+		if aliases := res.Entries[0].Aliases; len(aliases) != 1 || aliases[0].Domain != "bücher.de" || aliases[0].Count != 2 {
+			t.Errorf("alias mismatch error: wanted bücher.de with 2 rows ; got %v", aliases)
+		}
+	})
+}
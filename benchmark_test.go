@@ -2,7 +2,12 @@ package customerimporter
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	_ "embed"
@@ -13,6 +18,20 @@ var rawData []byte
 
 const rawPath = "./testdata/customers.csv"
 
+// sliceReader replays pre-parsed CSV records, to keep CSV parsing out of the benchmark
+type sliceReader struct {
+	records [][]string
+	idx     int
+}
+
+func (r *sliceReader) Read() ([]string, error) {
+	if r.idx >= len(r.records) {
+		return nil, io.EOF
+	}
+	r.idx++
+	return r.records[r.idx-1], nil
+}
+
 func BenchmarkParse(b *testing.B) {
 	var (
 		entries []Entry
@@ -28,6 +47,21 @@ func BenchmarkParse(b *testing.B) {
 	_ = entries
 }
 
+func BenchmarkParseReader(b *testing.B) {
+	var (
+		entries []Entry
+		err     error
+	)
+	for i := 0; i < b.N; i++ {
+		entries, err = ParseReader(bytes.NewReader(rawData))
+		if err != nil {
+			b.Error(err)
+			return
+		}
+	}
+	_ = entries
+}
+
 func BenchmarkMapAndSort(b *testing.B) {
 	buf := bytes.NewBuffer(rawData)
 	r := csv.NewReader(buf)
@@ -38,15 +72,62 @@ func BenchmarkMapAndSort(b *testing.B) {
 		return
 	}
 
-	var output []Entry
+	var (
+		output []Entry
+		cfg    = newConfig()
+	)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		entryMap, err := mapEmailRow(records)
-		if err != nil {
+		c := newCounter(cfg)
+		if err := c.mapEmailRow(context.Background(), &sliceReader{records: records}); err != nil {
 			b.Error(err)
 			return
 		}
-		output = sortResults(entryMap)
+		output = sortResults(c.domains, cfg.sortOrder)
 	}
 	_ = output
 }
+
+func BenchmarkParseWorkers(b *testing.B) {
+	// repeated headers are skipped, so the copies read as one larger file
+	data := bytes.Repeat(rawData, 20)
+
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseReader(bytes.NewReader(data), WithWorkers(workers)); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseMemoryMap(b *testing.B) {
+	// repeated headers are skipped, so the copies read as one larger file
+	path := filepath.Join(b.TempDir(), "customers.csv")
+	if err := os.WriteFile(path, bytes.Repeat(rawData, 20), 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Buffered"},
+		{name: "MemoryMap", opts: []Option{WithMemoryMap()}},
+		{name: "BufferedWorkers", opts: []Option{WithWorkers(4)}},
+		{name: "MemoryMapWorkers", opts: []Option{WithMemoryMap(), WithWorkers(4)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(path, bench.opts...); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package customerimporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CanceledError is returned when parsing stops because its context is done, along with the
+// results counted until then. It holds the progress made, and wraps the context's error, so
+// `errors.Is(err, context.Canceled)` reports whether parsing was canceled.
+//
+// With WithWorkers, the records are counted out of order: Line is the furthest record read,
+// and not all of the rows before it may have been counted
+type CanceledError struct {
+	// Rows is the number of rows counted before stopping
+	Rows int
+	// Line is the line number of the last record read
+	Line int
+	Err  error
+}
+
+// Error implements the error interface
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("stopped after %d rows, at line %d: %v", e.Rows, e.Line, e.Err)
+}
+
+// Unwrap returns the context's error
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// canceled returns a CanceledError with the counter's progress if `ctx` is done, or nil otherwise
+func (c *counter) canceled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &CanceledError{Rows: c.rows, Line: c.line, Err: err}
+	}
+
+	return nil
+}
+
+// isCanceled reports whether `err` is a CanceledError, after which the partial results are kept
+func isCanceled(err error) bool {
+	var canceledErr *CanceledError
+	return errors.As(err, &canceledErr)
+}
@@ -0,0 +1,158 @@
+package customerimporter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+// cancelReader calls cancel once more than `after` bytes were read from r
+type cancelReader struct {
+	r      io.Reader
+	after  int
+	read   int
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += n
+	if r.read > r.after {
+		r.cancel()
+	}
+	return n, err
+}
+
+// cancelResolver calls cancel on the first lookup, failing it with the context's error
+type cancelResolver struct {
+	cancel context.CancelFunc
+}
+
+func (r cancelResolver) LookupMX(ctx context.Context, _ string) ([]*net.MX, error) {
+	r.cancel()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (r cancelResolver) LookupHost(ctx context.Context, _ string) ([]string, error) {
+	r.cancel()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestContext(t *testing.T) {
+	input := parallelInput(t)
+	opts := []Option{WithComment('#'), WithErrorPolicy(SkipAndCount)}
+
+	total, err := CountReader(bytes.NewReader(input), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("NotCanceled", func(t *testing.T) {
+		res, err := CountReaderContext(context.Background(), bytes.NewReader(input), opts...)
+		if err != nil {
+			t.Errorf("unexpected error: wanted nil ; got %v", err)
+			return
+		}
+
+		res.Elapsed = 0
+		wanted := *total
+		wanted.Elapsed = 0
+		if !reflect.DeepEqual(&wanted, res) {
+			t.Errorf("output mismatch error: wanted %d rows ; got %d rows", wanted.Rows, res.Rows)
+		}
+	})
+
+	for _, testcase := range []struct {
+		name  string
+		after int
+		opts  []Option
+		// progress is set when some rows are surely counted before canceling; the workers may
+		// not have started on any chunk yet
+		progress bool
+	}{
+		{name: "Sequential", after: len(input) / 3, progress: true},
+		{name: "Workers", after: len(input) / 3, opts: []Option{WithWorkers(3)}},
+		{name: "Dedup", after: len(input) / 3, opts: []Option{WithDedup()}, progress: true},
+		{name: "Before", after: -1},
+		{name: "BeforeWorkers", after: -1, opts: []Option{WithWorkers(3)}},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			r := &cancelReader{r: bytes.NewReader(input), after: testcase.after, cancel: cancel}
+			res, err := CountReaderContext(ctx, r, append(opts, testcase.opts...)...)
+
+			var canceledErr *CanceledError
+			if !errors.As(err, &canceledErr) || !errors.Is(err, context.Canceled) {
+				t.Errorf("unexpected error: wanted %v ; got %v", context.Canceled, err)
+				return
+			}
+			if res == nil {
+				t.Errorf("expected partial results")
+				return
+			}
+
+			if canceledErr.Rows != res.Rows || res.Rows >= total.Rows {
+				t.Errorf("rows mismatch error: wanted %d (out of %d) ; got %d", res.Rows, total.Rows, canceledErr.Rows)
+			}
+			if testcase.progress && (res.Rows == 0 || canceledErr.Line <= 1) {
+				t.Errorf("expected progress before canceling: got %d rows, at line %d", res.Rows, canceledErr.Line)
+			}
+
+			var counted int
+			for _, e := range res.Entries {
+				counted += e.Count
+			}
+			if counted != res.Valid {
+				t.Errorf("output mismatch error: wanted %d valid rows ; got %d in the entries", res.Valid, counted)
+			}
+		})
+	}
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+
+		entries, err := ParseContext(ctx, "./testdata/customers.csv")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: wanted %v ; got %v", context.DeadlineExceeded, err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("output mismatch error: wanted no entries ; got %d", len(entries))
+		}
+	})
+
+	t.Run("DNS", func(t *testing.T) {
+		// the rows are all counted, but the lookups are stopped
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		input := "email\njohn@example.com\njane@example.org\n"
+		v := NewDNSValidator(cancelResolver{cancel: cancel}, 1, time.Second)
+
+		entries, err := ParseReaderContext(ctx, strings.NewReader(input), WithDNSValidation(v))
+
+		var canceledErr *CanceledError
+		if !errors.As(err, &canceledErr) || !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: wanted %v ; got %v", context.Canceled, err)
+			return
+		}
+		if canceledErr.Rows != 2 || canceledErr.Line != 3 {
+			t.Errorf("progress mismatch error: wanted 2 rows at line 3 ; got %d rows at line %d", canceledErr.Rows, canceledErr.Line)
+		}
+		if len(entries) != 2 {
+			t.Errorf("output mismatch error: wanted 2 entries ; got %d", len(entries))
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed data/domain_categories.csv
+var embeddedCategories []byte
+
+var (
+	defaultClassifier     *Classifier
+	defaultClassifierOnce sync.Once
+)
+
+var ErrInvalidCategory = errors.New("invalid domain category")
+
+// Category classifies the domains of email addresses by the kind of organization behind them
+type Category uint8
+
+const (
+	// CategoryUnknown is a domain that could not be classified, such as one with an unknown
+	// top-level domain or an address literal
+	CategoryUnknown Category = iota
+	// CategoryFreeMail is a consumer email provider, such as `gmail.com` or `163.com`
+	CategoryFreeMail
+	// CategoryCorporate is a business or organization domain, not matching any other category
+	CategoryCorporate
+	// CategoryEducation is an academic domain, such as `mit.edu` or `ox.ac.uk`
+	CategoryEducation
+	// CategoryGovernment is a government or military domain, such as `nasa.gov` or `gov.uk`
+	CategoryGovernment
+)
+
+// categories lists the categories in order, as reported by Result.Categories
+var categories = []Category{
+	CategoryFreeMail,
+	CategoryCorporate,
+	CategoryEducation,
+	CategoryGovernment,
+	CategoryUnknown,
+}
+
+var categoryNames = map[Category]string{
+	CategoryUnknown:    "unknown",
+	CategoryFreeMail:   "free-mail",
+	CategoryCorporate:  "corporate",
+	CategoryEducation:  "education",
+	CategoryGovernment: "government",
+}
+
+var categoryAliases = map[string]Category{
+	"freemail": CategoryFreeMail,
+	"webmail":  CategoryFreeMail,
+	"business": CategoryCorporate,
+	"edu":      CategoryEducation,
+	"gov":      CategoryGovernment,
+}
+
+// String implements the fmt.Stringer interface
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Category(%d)", c)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (c Category) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// ParseCategory returns the Category for `name`, one of `free-mail` (or `freemail`, `webmail`),
+// `corporate` (or `business`), `education` (or `edu`), `government` (or `gov`) and `unknown`
+func ParseCategory(name string) (Category, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for c, n := range categoryNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	if c, ok := categoryAliases[name]; ok {
+		return c, nil
+	}
+
+	return CategoryUnknown, fmt.Errorf("%w: %q", ErrInvalidCategory, name)
+}
+
+// academicLabels and governmentLabels are the second-level labels used by country-code
+// TLDs for academic and government domains, such as `ac.be` or `gob.ar`
+var (
+	academicLabels   = map[string]struct{}{"ac": {}, "edu": {}}
+	governmentLabels = map[string]struct{}{"gov": {}, "gob": {}, "gouv": {}, "govt": {}, "go": {}, "gv": {}, "mil": {}}
+)
+
+// Classifier assigns a Category to domains, from a set of rules mapping domains (and their
+// subdomains) to a Category. Domains without a matching rule are classified with heuristics
+// on their top-level domain. It is safe for concurrent reads, but not for concurrent calls
+// to Add or Load
+type Classifier struct {
+	rules map[string]Category
+}
+
+// NewClassifier creates a Classifier without any rules, relying only on the TLD heuristics
+func NewClassifier() *Classifier {
+	return &Classifier{rules: map[string]Category{}}
+}
+
+// DefaultClassifier returns a copy of the Classifier with the rules embedded in the package,
+// which can be extended with Add or Load
+func DefaultClassifier() *Classifier {
+	defaultClassifierOnce.Do(func() {
+		c := NewClassifier()
+		if err := c.Load(bytes.NewReader(embeddedCategories)); err != nil {
+			panic(fmt.Sprintf("customerimporter: invalid embedded domain categories: %v", err))
+		}
+		defaultClassifier = c
+	})
+
+	c := &Classifier{rules: make(map[string]Category, len(defaultClassifier.rules))}
+	for domain, category := range defaultClassifier.rules {
+		c.rules[domain] = category
+	}
+
+	return c
+}
+
+// Add classifies the input `domains`, and their subdomains, with the Category `category`.
+// Rules for more specific domains take precedence, so `gov.uk` can be government while
+// `uk` is not
+func (c *Classifier) Add(category Category, domains ...string) {
+	for _, domain := range domains {
+		if domain = canonicalDomain(domain); domain == "" {
+			continue
+		}
+		c.rules[domain] = category
+	}
+}
+
+// Load reads classification rules from the CSV data in `r`, with a `domain,category`
+// header and one rule per row. Lines starting with `#` are ignored
+func (c *Classifier) Load(r io.Reader) error {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = 2
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	for _, record := range records[1:] {
+		category, err := ParseCategory(record[1])
+		if err != nil {
+			return fmt.Errorf("%w for %q", err, record[0])
+		}
+
+		c.Add(category, record[0])
+	}
+
+	return nil
+}
+
+// Len returns the number of rules in the Classifier
+func (c *Classifier) Len() int {
+	return len(c.rules)
+}
+
+// Classify returns the Category for `domain`. The most specific rule matching the domain or
+// one of its parent domains wins; otherwise, second-level labels such as `ac` or `gov` under
+// a country-code TLD mark education and government domains, and any other domain under a
+// known TLD is corporate
+func (c *Classifier) Classify(domain string) Category {
+	if !isASCII(domain) {
+		if ascii, err := ToASCII(domain); err == nil {
+			domain = ascii
+		}
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	if domain == "" || strings.HasPrefix(domain, "[") {
+		return CategoryUnknown
+	}
+
+	for suffix := domain; ; {
+		if category, ok := c.rules[suffix]; ok {
+			return category
+		}
+
+		idx := strings.IndexByte(suffix, '.')
+		if idx < 0 {
+			break
+		}
+		suffix = suffix[idx+1:]
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return CategoryUnknown
+	}
+
+	info := LookupTLD(labels[len(labels)-1])
+	switch info.Kind {
+	case TLDUnknown, TLDInfrastructure:
+		return CategoryUnknown
+	case TLDCountry:
+		if len(labels) > 2 {
+			second := labels[len(labels)-2]
+			if _, ok := academicLabels[second]; ok {
+				return CategoryEducation
+			}
+			if _, ok := governmentLabels[second]; ok {
+				return CategoryGovernment
+			}
+		}
+	}
+
+	return CategoryCorporate
+}
+
+// CategoryTotal is the count of addresses in domains of a Category, as returned by
+// Result.Categories
+type CategoryTotal struct {
+	Category Category `json:"category"`
+	// Domains is the number of distinct domains in the Category
+	Domains int `json:"domains"`
+	// Count is the number of addresses in domains of the Category
+	Count int `json:"count"`
+	// Share is the percentage of the total count in the Category, rounded to 4 decimal places
+	Share float64 `json:"share"`
+}
+
+// Categories returns the totals for each Category, in the order free-mail, corporate,
+// education, government and unknown. Entries are only classified when parsing with
+// WithClassifier; otherwise, all of them are counted as unknown
+func (r *Result) Categories() []CategoryTotal {
+	totals := make(map[Category]*CategoryTotal, len(categories))
+	output := make([]CategoryTotal, len(categories))
+	for idx, category := range categories {
+		output[idx].Category = category
+		totals[category] = &output[idx]
+	}
+
+	for _, e := range r.Entries {
+		total, ok := totals[e.Category]
+		if !ok {
+			continue
+		}
+
+		total.Domains++
+		total.Count += e.Count
+	}
+
+	sum := r.Total()
+	for idx := range output {
+		output[idx].Share = percentage(output[idx].Count, sum)
+	}
+
+	return output
+}
@@ -0,0 +1,144 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestClassifier(t *testing.T) {
+	cl := DefaultClassifier()
+	cl.Add(CategoryCorporate, "corp.mail.ru")
+
+	for _, testcase := range []struct {
+		domain string
+		wants  Category
+	}{
+		{domain: "gmail.com", wants: CategoryFreeMail},
+		{domain: "GoogleMail.com", wants: CategoryFreeMail},
+		{domain: "163.com", wants: CategoryFreeMail},
+		{domain: "yandex.ru", wants: CategoryFreeMail},
+		{domain: "acme.com", wants: CategoryCorporate},
+		{domain: "mail.acme.co.uk", wants: CategoryCorporate},
+		{domain: "corp.mail.ru", wants: CategoryCorporate},
+		{domain: "acme.cloud", wants: CategoryCorporate},
+		{domain: "acme.agency", wants: CategoryCorporate},
+		{domain: "mit.edu", wants: CategoryEducation},
+		{domain: "cs.ox.ac.uk", wants: CategoryEducation},
+		{domain: "kuleuven.ac.be", wants: CategoryEducation},
+		{domain: "nasa.gov", wants: CategoryGovernment},
+		{domain: "army.mil", wants: CategoryGovernment},
+		{domain: "hmrc.gov.uk", wants: CategoryGovernment},
+		{domain: "afip.gob.ar", wants: CategoryGovernment},
+		{domain: "example.unknowntld", wants: CategoryUnknown},
+		{domain: "localhost", wants: CategoryUnknown},
+		{domain: "[192.0.2.1]", wants: CategoryUnknown},
+		{domain: "", wants: CategoryUnknown},
+	} {
+		t.Run(testcase.domain, func(t *testing.T) {
+			if category := cl.Classify(testcase.domain); category != testcase.wants {
+				t.Errorf("output mismatch error: wanted %v ; got %v", testcase.wants, category)
+			}
+		})
+	}
+
+	t.Run("DefaultIsCopied", func(t *testing.T) {
+		if category := DefaultClassifier().Classify("corp.mail.ru"); category != CategoryFreeMail {
+			t.Errorf("expected changes to a default classifier not to leak into other copies; got %v", category)
+		}
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		cl := NewClassifier()
+		if err := cl.Load(strings.NewReader("domain,category\n# custom rules\nmail.example,webmail\nuni.example, edu\n")); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if cl.Len() != 2 {
+			t.Errorf("rule count mismatch: wanted %d ; got %d", 2, cl.Len())
+		}
+		if category := cl.Classify("mail.example"); category != CategoryFreeMail {
+			t.Errorf("output mismatch error: wanted %v ; got %v", CategoryFreeMail, category)
+		}
+		if category := cl.Classify("cs.uni.example"); category != CategoryEducation {
+			t.Errorf("output mismatch error: wanted %v ; got %v", CategoryEducation, category)
+		}
+
+		err := cl.Load(strings.NewReader("domain,category\nbad.example,charity\n"))
+		if !errors.Is(err, ErrInvalidCategory) {
+			t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidCategory, err)
+		}
+	})
+}
+
+func TestParseCategory(t *testing.T) {
+	for _, testcase := range []struct {
+		name  string
+		wants Category
+		err   error
+	}{
+		{name: "free-mail", wants: CategoryFreeMail},
+		{name: "Webmail", wants: CategoryFreeMail},
+		{name: "corporate", wants: CategoryCorporate},
+		{name: "edu", wants: CategoryEducation},
+		{name: "government", wants: CategoryGovernment},
+		{name: "unknown", wants: CategoryUnknown},
+		{name: "charity", err: ErrInvalidCategory},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			category, err := ParseCategory(testcase.name)
+			if !errors.Is(err, testcase.err) {
+				t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+				return
+			}
+			if category != testcase.wants {
+				t.Errorf("output mismatch error: wanted %v ; got %v", testcase.wants, category)
+			}
+		})
+	}
+}
+
+func TestCategories(t *testing.T) {
+	const input = `email
+a@gmail.com
+b@gmail.com
+c@outlook.com
+d@acme.com
+e@mit.edu
+f@nasa.gov
+g@example.unknowntld
+h@acme.com
+`
+
+	res, err := CountReader(strings.NewReader(input), WithClassifier(nil))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	wants := []CategoryTotal{
+		{Category: CategoryFreeMail, Domains: 2, Count: 3, Share: 37.5},
+		{Category: CategoryCorporate, Domains: 1, Count: 2, Share: 25},
+		{Category: CategoryEducation, Domains: 1, Count: 1, Share: 12.5},
+		{Category: CategoryGovernment, Domains: 1, Count: 1, Share: 12.5},
+		{Category: CategoryUnknown, Domains: 1, Count: 1, Share: 12.5},
+	}
+
+	totals := res.Categories()
+	if len(totals) != len(wants) {
+		t.Errorf("output length mismatch error: wanted %d ; got %d", len(wants), len(totals))
+		return
+	}
+	for idx := range wants {
+		if totals[idx] != wants[idx] {
+			t.Errorf("output mismatch error on index %d: wanted %+v ; got %+v", idx, wants[idx], totals[idx])
+		}
+	}
+
+	if e, ok := res.Lookup("mit.edu"); !ok || e.Category != CategoryEducation {
+		t.Errorf("output mismatch error: wanted mit.edu as %v ; got %v", CategoryEducation, e)
+	}
+}
@@ -1,35 +1,535 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	customerimporter "github.com/zalgonoise/emailimp"
 )
 
+const usage = `usage: emailimp [command] -f <path> [flags]
+
+commands:
+  count  list the number of customers per domain (default)
+  tld    list the number of customers per top-level domain or country
+`
+
 func main() {
-	filePath := flag.String("f", "", "path to the file to parse")
-	flag.Parse()
+	name, args := "count", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	if *filePath == "" {
-		log.Fatal("no input file provided")
-		os.Exit(1)
+	// parsing stops on the first interrupt; a second one terminates right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	var err error
+	switch name {
+	case "count":
+		err = runCount(ctx, args)
+	case "tld":
+		err = runTLD(ctx, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		err = fmt.Errorf("unknown command: %q", name)
 	}
 
-	entries, err := customerimporter.Parse(*filePath)
 	if err != nil {
 		log.Fatal(err)
 		os.Exit(1)
 	}
+	os.Exit(0)
+}
 
-	sb := &strings.Builder{}
-	sb.WriteString("Listing entries:\n")
-	for _, e := range entries {
-		sb.WriteString(fmt.Sprintf("  - %s: %d\n", e.Domain, e.Count))
+func runCount(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("count", flag.ExitOnError)
+	common := newCommonFlags(fs)
+	sortOrder := fs.String("sort", "domain", "sort order: domain, count-desc, count-asc, reverse-label or tld")
+	format := fs.String("format", "text", "output format: text, json, ndjson, csv, tsv, markdown, tree or tree-json")
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
-	fmt.Print(sb.String())
-	os.Exit(0)
+
+	order, err := customerimporter.ParseSortOrder(*sortOrder)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := customerimporter.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	res, err := common.count(ctx, customerimporter.WithSort(order))
+	if res == nil {
+		return err
+	}
+
+	if writeErr := common.write(func(w io.Writer) error {
+		return res.Encode(w, outputFormat)
+	}); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+func runTLD(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tld", flag.ExitOnError)
+	common := newCommonFlags(fs)
+	countries := fs.Bool("countries", false, "list the counts per country (for country-code TLDs) instead of per TLD")
+	format := fs.String("format", "text", "output format: text, json, ndjson, csv, tsv or markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	outputFormat, err := customerimporter.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	res, err := common.count(ctx)
+	if res == nil {
+		return err
+	}
+
+	report := res.TLDReport()
+	if writeErr := common.write(func(w io.Writer) error {
+		if *countries {
+			return report.EncodeCountries(w, outputFormat)
+		}
+		return report.Encode(w, outputFormat)
+	}); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// commonFlags holds the flags shared by all commands, to read the input and write the output
+type commonFlags struct {
+	filePath    *string
+	output      *string
+	columns     *string
+	index       *int
+	delimiter   *string
+	comment     *string
+	lazyQuotes  *bool
+	skipInvalid *bool
+	normalize   *string
+	strict      *bool
+	idna        *string
+	rollup      *bool
+	pslPath     *string
+	disposable  *bool
+	disposables *string
+	classify    *bool
+	categories  *string
+	aliases     *string
+	showAliases *bool
+	typos       *bool
+	fixTypos    *bool
+	dns         *bool
+	dnsWorkers  *int
+	dnsTimeout  *time.Duration
+	dedup       *bool
+	topDups     *int
+	approxDedup *float64
+	sketches    *string
+	topK        *int
+	topCapacity *int
+	workers     *int
+	mmap        *bool
+	partial     *bool
+}
+
+func newCommonFlags(fs *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		filePath:    fs.String("f", "", "path to the file to parse"),
+		output:      fs.String("o", "", "path to the output file (defaults to stdout)"),
+		columns:     fs.String("column", "", "comma-separated header names for the email column (case-insensitive)"),
+		index:       fs.Int("index", -1, "zero-based index of the email column, for files without a header"),
+		delimiter:   fs.String("d", ",", "field delimiter; use 'auto' to detect it, or '\\t' for tabs"),
+		comment:     fs.String("comment", "", "comment character; lines starting with it are ignored"),
+		lazyQuotes:  fs.Bool("lazy-quotes", false, "allow quotes in unquoted fields and non-doubled quotes in quoted fields"),
+		skipInvalid: fs.Bool("skip-invalid", false, "skip invalid rows instead of failing, logging them to stderr"),
+		normalize:   fs.String("normalize", "all", "comma-separated address normalization rules: trim, angle, last-at, trailing-dot, lower; or all / none"),
+		strict:      fs.Bool("strict", false, "validate the syntax of each address (RFC 5322 / RFC 5321)"),
+		idna:        fs.String("idna", "none", "internationalized domain handling: none, ascii or unicode"),
+		rollup:      fs.Bool("rollup", false, "group counts by registrable domain (eTLD+1)"),
+		pslPath:     fs.String("psl", "", "path to a Public Suffix List file for -rollup (defaults to the embedded snapshot)"),
+		disposable:  fs.Bool("disposable", false, "flag disposable email providers and log their share of customers"),
+		disposables: fs.String("disposable-list", "", "path to a file with extra disposable domains for -disposable, one per line"),
+		classify:    fs.Bool("classify", false, "classify domains as free-mail, corporate, education or government, and log the totals"),
+		categories:  fs.String("categories", "", "path to a CSV file (domain,category) with extra rules for -classify"),
+		aliases:     fs.String("aliases", "", "path to a CSV (alias,canonical) or JSON file mapping alias domains to their canonical domain"),
+		showAliases: fs.Bool("show-aliases", false, "log the aliases folded into each canonical domain, with their counts"),
+		typos:       fs.Bool("typos", false, "log the domains that are likely misspellings of another one, with a suggested correction"),
+		fixTypos:    fs.Bool("fix-typos", false, "merge the counts of likely misspelled domains into their suggested correction"),
+		dns:         fs.Bool("dns", false, "check the MX and address records of each domain, and log the undeliverable ones"),
+		dnsWorkers:  fs.Int("dns-workers", 8, "maximum number of concurrent DNS lookups for -dns"),
+		dnsTimeout:  fs.Duration("dns-timeout", 5*time.Second, "time limit for the DNS lookups of each domain for -dns"),
+		dedup:       fs.Bool("dedup", false, "count the distinct addresses per domain, and log the most duplicated addresses"),
+		topDups:     fs.Int("top-duplicates", 10, "number of duplicated addresses to log with -dedup"),
+		approxDedup: fs.Float64("approx-dedup", 0, "estimate the distinct addresses with HyperLogLog sketches, with this relative error (such as 0.01)"),
+		sketches:    fs.String("sketches", "", "path to a JSON file to merge the -approx-dedup sketches into, across runs"),
+		topK:        fs.Int("top", 0, "only keep the top N domains, counted within a fixed memory budget"),
+		topCapacity: fs.Int("top-capacity", 0, "number of counters for -top, bounding memory usage and the count error (defaults to 100 times N, and at least 1000)"),
+		workers:     fs.Int("workers", 1, "number of goroutines counting the domains in parallel; use 0 for one per CPU"),
+		mmap:        fs.Bool("mmap", false, "read the file through a memory mapping, on Linux"),
+		partial:     fs.Bool("partial", false, "when interrupted (SIGINT or SIGTERM), still write the results counted so far"),
+	}
+}
+
+func (f *commonFlags) options() ([]customerimporter.Option, error) {
+	var opts []customerimporter.Option
+
+	if *f.columns != "" {
+		opts = append(opts, customerimporter.WithColumnNames(strings.Split(*f.columns, ",")...))
+	}
+	if *f.index >= 0 {
+		opts = append(opts, customerimporter.WithColumnIndex(*f.index))
+	}
+
+	switch *f.delimiter {
+	case "auto":
+		opts = append(opts, customerimporter.WithDelimiterDetection())
+	case "\\t", "tab":
+		opts = append(opts, customerimporter.WithComma('\t'))
+	default:
+		r := []rune(*f.delimiter)
+		if len(r) != 1 {
+			return nil, fmt.Errorf("invalid delimiter: %q", *f.delimiter)
+		}
+		opts = append(opts, customerimporter.WithComma(r[0]))
+	}
+
+	if r := []rune(*f.comment); len(r) == 1 {
+		opts = append(opts, customerimporter.WithComment(r[0]))
+	}
+	if *f.lazyQuotes {
+		opts = append(opts, customerimporter.WithLazyQuotes())
+	}
+
+	normalization, err := customerimporter.ParseNormalization(*f.normalize)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, customerimporter.WithNormalization(normalization))
+
+	idnaForm, err := customerimporter.ParseIDNAForm(*f.idna)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, customerimporter.WithIDNA(idnaForm))
+
+	if *f.rollup {
+		var list *customerimporter.SuffixList
+		if *f.pslPath != "" {
+			if list, err = loadSuffixList(*f.pslPath); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, customerimporter.WithRollup(list))
+	}
+
+	if *f.disposable {
+		list := customerimporter.DefaultDisposableDomains()
+		if *f.disposables != "" {
+			if err = extendDomainList(list, *f.disposables); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, customerimporter.WithDisposableDomains(list))
+	}
+
+	if *f.classify {
+		cl := customerimporter.DefaultClassifier()
+		if *f.categories != "" {
+			if err = loadCategories(cl, *f.categories); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, customerimporter.WithClassifier(cl))
+	}
+
+	if *f.aliases != "" {
+		m, err := loadAliasMap(*f.aliases)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, customerimporter.WithAliases(m))
+	}
+
+	switch {
+	case *f.fixTypos:
+		opts = append(opts, customerimporter.WithTypoCorrection(nil))
+	case *f.typos:
+		opts = append(opts, customerimporter.WithTypoDetection(nil))
+	}
+
+	if *f.dns {
+		opts = append(opts, customerimporter.WithDNSValidation(
+			customerimporter.NewDNSValidator(nil, *f.dnsWorkers, *f.dnsTimeout),
+		))
+	}
+
+	switch {
+	case *f.approxDedup > 0:
+		opts = append(opts, customerimporter.WithApproximateDedup(*f.approxDedup))
+	case *f.dedup:
+		opts = append(opts, customerimporter.WithDedup())
+	}
+
+	if *f.topK > 0 {
+		opts = append(opts, customerimporter.WithTopK(*f.topK, *f.topCapacity))
+	}
+
+	if *f.workers != 1 {
+		opts = append(opts, customerimporter.WithWorkers(*f.workers))
+	}
+	if *f.mmap {
+		opts = append(opts, customerimporter.WithMemoryMap())
+	}
+
+	if *f.strict {
+		opts = append(opts, customerimporter.WithStrictValidation())
+	}
+	if *f.skipInvalid {
+		opts = append(opts, customerimporter.WithErrorPolicy(customerimporter.SkipAndCollect))
+	}
+
+	return opts, nil
+}
+
+// count parses the input file with the options from the flags and `extra`, logging the
+// skipped rows, until `ctx` is done. When interrupted with -partial, it returns the results
+// counted so far along with the error
+func (f *commonFlags) count(ctx context.Context, extra ...customerimporter.Option) (*customerimporter.Result, error) {
+	if *f.filePath == "" {
+		return nil, fmt.Errorf("no input file provided")
+	}
+
+	opts, err := f.options()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := customerimporter.CountContext(ctx, *f.filePath, append(opts, extra...)...)
+	var canceledErr *customerimporter.CanceledError
+	switch {
+	case errors.As(err, &canceledErr) && *f.partial:
+		log.Printf("interrupted: partial results for %d rows, up to line %d", canceledErr.Rows, canceledErr.Line)
+	case err != nil:
+		return nil, err
+	}
+
+	if res.Skipped.Count > 0 {
+		for _, rowErr := range res.Skipped.Errors {
+			log.Printf("skipped %v", rowErr)
+		}
+		log.Printf("skipped %d invalid rows", res.Skipped.Count)
+	}
+
+	if *f.disposable {
+		summary := res.Disposable()
+		log.Printf("disposable: %d customers (%s%%) in %d domains", summary.Count,
+			strconv.FormatFloat(summary.Share, 'f', 2, 64), summary.Domains)
+	}
+
+	if *f.showAliases {
+		for _, e := range res.Entries {
+			for _, alias := range e.Aliases {
+				log.Printf("folded %s into %s: %d customers", alias.Domain, e.Domain, alias.Count)
+			}
+		}
+	}
+
+	for _, typo := range res.Typos {
+		log.Printf("possible typo: %s (%d customers) for %s, with %s confidence", typo.Domain, typo.Count,
+			typo.Suggestion, strconv.FormatFloat(typo.Confidence, 'f', 2, 64))
+	}
+
+	if *f.dedup {
+		for _, dup := range res.TopDuplicates(*f.topDups) {
+			log.Printf("duplicated: %s (%d rows)", dup.Address, dup.Count)
+		}
+	}
+
+	if res.ErrorBound > 0 {
+		log.Printf("top %d domains: the domains left out have up to %d customers", *f.topK, res.ErrorBound)
+
+		// once a count's error reaches the bound, it may be no larger than the domains left out
+		for _, e := range res.Entries {
+			if e.Error > 0 && e.Count-e.Error < res.ErrorBound {
+				log.Printf("top %d domains: the counts are upper bounds and the ranking is unreliable; raise -top-capacity", *f.topK)
+				break
+			}
+		}
+	}
+
+	if res.Sketch != nil {
+		total := res.Sketch
+		if *f.sketches != "" {
+			var mergeErr error
+			if total, mergeErr = mergeSketchFile(*f.sketches, res); mergeErr != nil {
+				return nil, mergeErr
+			}
+		}
+		log.Printf("distinct customers: ~%d (±%s%%)", total.Estimate(),
+			strconv.FormatFloat(total.RelativeError()*100, 'f', 2, 64))
+	}
+
+	if *f.dns {
+		for _, e := range res.Entries {
+			if !e.Deliverability.Deliverable() {
+				log.Printf("undeliverable: %s (%d customers): %s", e.Domain, e.Count, e.Deliverability)
+			}
+		}
+	}
+
+	if *f.classify {
+		for _, total := range res.Categories() {
+			log.Printf("%s: %d customers (%s%%) in %d domains", total.Category, total.Count,
+				strconv.FormatFloat(total.Share, 'f', 2, 64), total.Domains)
+		}
+	}
+
+	return res, err
+}
+
+// write calls `encode` with the output file, or stdout if none is set
+func (f *commonFlags) write(encode func(w io.Writer) error) error {
+	w := os.Stdout
+	if *f.output != "" {
+		var err error
+		if w, err = os.Create(*f.output); err != nil {
+			return err
+		}
+	}
+
+	if err := encode(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func loadSuffixList(path string) (*customerimporter.SuffixList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return customerimporter.LoadSuffixList(f)
+}
+
+// extendDomainList adds the domains in the file at `path` to `list`
+func extendDomainList(list *customerimporter.DomainList, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	extra, err := customerimporter.LoadDomainList(f)
+	if err != nil {
+		return err
+	}
+
+	list.Merge(extra)
+	return nil
+}
+
+// loadCategories adds the classification rules in the file at `path` to `cl`
+func loadCategories(cl *customerimporter.Classifier, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return cl.Load(f)
+}
+
+func loadAliasMap(path string) (*customerimporter.AliasMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return customerimporter.LoadAliasMap(f)
+}
+
+// sketchFile is the JSON document holding the HyperLogLog sketches merged across runs
+type sketchFile struct {
+	Total   *customerimporter.Sketch            `json:"total"`
+	Domains map[string]*customerimporter.Sketch `json:"domains"`
+}
+
+// mergeSketchFile merges the sketches in the Result `res` into the ones in the file at `path`
+// (if it exists), and writes them back. Returns the merged global sketch
+func mergeSketchFile(path string, res *customerimporter.Result) (*customerimporter.Sketch, error) {
+	file := sketchFile{
+		Total:   res.Sketch.Clone(),
+		Domains: make(map[string]*customerimporter.Sketch, len(res.DomainSketches)),
+	}
+
+	prev, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var stored sketchFile
+		if err = json.Unmarshal(prev, &stored); err != nil {
+			return nil, fmt.Errorf("invalid sketch file %q: %w", path, err)
+		}
+
+		// null sketches in the file are replaced by the ones in the Result
+		if stored.Total != nil {
+			if err = stored.Total.Merge(res.Sketch); err != nil {
+				return nil, err
+			}
+			file.Total = stored.Total
+		}
+		for domain, s := range stored.Domains {
+			if s != nil {
+				file.Domains[domain] = s
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	for domain, s := range res.DomainSketches {
+		if prev, ok := file.Domains[domain]; ok {
+			if err = prev.Merge(s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		file.Domains[domain] = s
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return nil, err
+	}
+
+	return file.Total, nil
 }
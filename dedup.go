@@ -0,0 +1,31 @@
+package customerimporter
+
+import "sort"
+
+// AddressCount is the number of rows with the same (normalized) email address
+type AddressCount struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+}
+
+// TopDuplicates returns up to `n` of the most duplicated addresses, by descending count.
+// Duplicated addresses are only tracked when parsing with WithDedup
+func (r *Result) TopDuplicates(n int) []AddressCount {
+	if n < 0 || n > len(r.DuplicateAddresses) {
+		n = len(r.DuplicateAddresses)
+	}
+
+	top := make([]AddressCount, n)
+	copy(top, r.DuplicateAddresses)
+	return top
+}
+
+// sortAddressCounts sorts `addresses` by descending count, and by address when tied
+func sortAddressCounts(addresses []AddressCount) {
+	sort.Slice(addresses, func(i, j int) bool {
+		if addresses[i].Count != addresses[j].Count {
+			return addresses[i].Count > addresses[j].Count
+		}
+		return addresses[i].Address < addresses[j].Address
+	})
+}
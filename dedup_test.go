@@ -0,0 +1,106 @@
+package customerimporter_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestDedup(t *testing.T) {
+	const input = `email
+a@gmail.com
+A@Gmail.com
+a@gmail.com
+b@gmail.com
+jane@mail.company.com
+jane@MAIL.company.com.
+john@company.com
+b@gmail.com
+a@gmail.com
+`
+
+	t.Run("Flat", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input), WithDedup())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		for domain, wants := range map[string][2]int{
+			"gmail.com":        {6, 3},
+			"mail.company.com": {2, 1},
+			"company.com":      {1, 1},
+		} {
+			e, ok := res.Lookup(domain)
+			if !ok || e.Count != wants[0] || e.Unique != wants[1] {
+				t.Errorf("output mismatch error for %q: wanted %d rows and %d unique ; got %v", domain, wants[0], wants[1], e)
+			}
+		}
+
+		if res.Duplicates != 4 {
+			t.Errorf("duplicates mismatch error: wanted %d ; got %d", 4, res.Duplicates)
+		}
+
+		wants := []AddressCount{
+			{Address: "a@gmail.com", Count: 3},
+			{Address: "b@gmail.com", Count: 2},
+			{Address: "jane@mail.company.com", Count: 2},
+		}
+		if len(res.DuplicateAddresses) != len(wants) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d: %v", len(wants), len(res.DuplicateAddresses), res.DuplicateAddresses)
+			return
+		}
+		for idx := range wants {
+			if res.DuplicateAddresses[idx] != wants[idx] {
+				t.Errorf("output mismatch error on index %d: wanted %v ; got %v", idx, wants[idx], res.DuplicateAddresses[idx])
+			}
+		}
+
+		if top := res.TopDuplicates(1); len(top) != 1 || top[0] != wants[0] {
+			t.Errorf("output mismatch error: wanted %v ; got %v", wants[:1], top)
+		}
+		if top := res.TopDuplicates(10); len(top) != len(wants) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d", len(wants), len(top))
+		}
+	})
+
+	t.Run("Rollup", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input), WithDedup(), WithRollup(nil))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if e, ok := res.Lookup("company.com"); !ok || e.Count != 3 || e.Unique != 2 {
+			t.Errorf("output mismatch error: wanted 3 rows and 2 unique ; got %v", e)
+		}
+	})
+
+	t.Run("Parse", func(t *testing.T) {
+		entries, err := ParseReader(strings.NewReader(input), WithDedup(), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if entries[0].Domain != "gmail.com" || entries[0].Count != 6 || entries[0].Unique != 3 {
+			t.Errorf("output mismatch error: wanted gmail.com with 6 rows and 3 unique ; got %v", entries[0])
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if res.Duplicates != 4 {
+			t.Errorf("duplicates mismatch error: wanted %d ; got %d", 4, res.Duplicates)
+		}
+		if e, _ := res.Lookup("gmail.com"); e.Unique != 0 || len(res.DuplicateAddresses) != 0 {
+			t.Errorf("expected no unique counts without WithDedup; got %v and %v", e, res.DuplicateAddresses)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package customerimporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDNSConcurrency is the default number of concurrent lookups in a DNSValidator
+	defaultDNSConcurrency = 8
+	// defaultDNSTimeout is the default time limit for checking each domain in a DNSValidator
+	defaultDNSTimeout = 5 * time.Second
+)
+
+// Resolver looks up DNS records, as implemented by net.Resolver
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Deliverability describes whether a domain can receive email, according to its DNS records
+type Deliverability uint8
+
+const (
+	// DeliverabilityUnchecked is a domain that was not checked
+	DeliverabilityUnchecked Deliverability = iota
+	// DeliverabilityUnknown is a domain whose lookup failed, such as on a timeout or server failure
+	DeliverabilityUnknown
+	// DeliverabilityMX is a domain with MX records
+	DeliverabilityMX
+	// DeliverabilityAddress is a domain without MX records but with an address (A or AAAA)
+	// record, which is used as an implicit MX (RFC 5321, section 5.1)
+	DeliverabilityAddress
+	// DeliverabilityNullMX is a domain that explicitly does not accept email (RFC 7505)
+	DeliverabilityNullMX
+	// DeliverabilityNone is a domain that does not exist, or has neither MX nor address records
+	DeliverabilityNone
+)
+
+var deliverabilityNames = map[Deliverability]string{
+	DeliverabilityUnchecked: "unchecked",
+	DeliverabilityUnknown:   "unknown",
+	DeliverabilityMX:        "mx",
+	DeliverabilityAddress:   "address",
+	DeliverabilityNullMX:    "null-mx",
+	DeliverabilityNone:      "none",
+}
+
+// String implements the fmt.Stringer interface
+func (d Deliverability) String() string {
+	if name, ok := deliverabilityNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Deliverability(%d)", d)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (d Deliverability) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Deliverable reports whether the domain can receive email, through MX or address records
+func (d Deliverability) Deliverable() bool {
+	return d == DeliverabilityMX || d == DeliverabilityAddress
+}
+
+// DNSValidator checks the Deliverability of domains by looking up their MX records, and
+// their address records when there are none. Lookups run concurrently up to a limit, each
+// domain within a timeout, and conclusive results are cached for the lifetime of the
+// DNSValidator, so it can be reused across runs. A DNSValidator is safe for concurrent use
+type DNSValidator struct {
+	resolver    Resolver
+	concurrency int
+	timeout     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]Deliverability
+}
+
+// NewDNSValidator creates a DNSValidator that looks up domains with the Resolver `r`, running
+// up to `concurrency` lookups at once and giving up on each domain after `timeout`. A nil `r`
+// uses net.DefaultResolver, and zero values use 8 concurrent lookups and a 5 second timeout
+func NewDNSValidator(r Resolver, concurrency int, timeout time.Duration) *DNSValidator {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	if concurrency <= 0 {
+		concurrency = defaultDNSConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+
+	return &DNSValidator{
+		resolver:    r,
+		concurrency: concurrency,
+		timeout:     timeout,
+		cache:       map[string]Deliverability{},
+	}
+}
+
+// Check returns the Deliverability of `domain`. Address literals are not looked up, and
+// are always DeliverabilityUnknown
+func (v *DNSValidator) Check(ctx context.Context, domain string) Deliverability {
+	domain = canonicalDomain(domain)
+	if domain == "" || strings.HasPrefix(domain, "[") {
+		return DeliverabilityUnknown
+	}
+
+	v.mu.Lock()
+	d, ok := v.cache[domain]
+	v.mu.Unlock()
+	if ok {
+		return d
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	if d = v.lookup(ctx, domain); d != DeliverabilityUnknown {
+		v.mu.Lock()
+		v.cache[domain] = d
+		v.mu.Unlock()
+	}
+
+	return d
+}
+
+// CheckAll returns the Deliverability of each of the input `domains`, with concurrent lookups
+func (v *DNSValidator) CheckAll(ctx context.Context, domains []string) map[string]Deliverability {
+	var (
+		results = make(map[string]Deliverability, len(domains))
+		queue   = make(chan string)
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	workers := v.concurrency
+	if len(domains) < workers {
+		workers = len(domains)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for domain := range queue {
+				d := v.Check(ctx, domain)
+
+				mu.Lock()
+				results[domain] = d
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		if _, ok := seen[domain]; ok {
+			continue
+		}
+		seen[domain] = struct{}{}
+		queue <- domain
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// Annotate sets the Deliverability of the input `entries`, and of their children
+func (v *DNSValidator) Annotate(ctx context.Context, entries []Entry) {
+	var domains []string
+	var collect func([]Entry)
+	collect = func(entries []Entry) {
+		for _, e := range entries {
+			domains = append(domains, e.Domain)
+			collect(e.Children)
+		}
+	}
+	collect(entries)
+
+	results := v.CheckAll(ctx, domains)
+
+	var annotate func([]Entry)
+	annotate = func(entries []Entry) {
+		for idx := range entries {
+			entries[idx].Deliverability = results[entries[idx].Domain]
+			annotate(entries[idx].Children)
+		}
+	}
+	annotate(entries)
+}
+
+func (v *DNSValidator) lookup(ctx context.Context, domain string) Deliverability {
+	mxs, err := v.resolver.LookupMX(ctx, domain)
+	switch {
+	case err == nil && len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == ""):
+		return DeliverabilityNullMX
+	case err == nil && len(mxs) > 0:
+		return DeliverabilityMX
+	case err != nil && !isNotFound(err):
+		return DeliverabilityUnknown
+	}
+
+	addrs, err := v.resolver.LookupHost(ctx, domain)
+	switch {
+	case err == nil && len(addrs) > 0:
+		return DeliverabilityAddress
+	case err != nil && !isNotFound(err):
+		return DeliverabilityUnknown
+	}
+
+	return DeliverabilityNone
+}
+
+// isNotFound reports whether `err` is a conclusive DNS answer that the records do not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
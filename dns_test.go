@@ -0,0 +1,339 @@
+package customerimporter_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeMX   = 15
+	dnsClassIN  = 1
+	dnsNoError  = 0
+	dnsServFail = 2
+	dnsNXDomain = 3
+)
+
+// fakeZone describes how the fakeDNSServer answers the queries for a name
+type fakeZone struct {
+	mx       []string
+	a        []net.IP
+	servfail bool
+	// silent zones never get an answer, so the queries time out
+	silent bool
+}
+
+// fakeDNSServer is an in-process DNS server over UDP, answering MX and A queries from a
+// fixed set of zones. Names that are not in the zones get an NXDOMAIN answer
+type fakeDNSServer struct {
+	conn  net.PacketConn
+	zones map[string]fakeZone
+
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func newFakeDNSServer(t *testing.T, zones map[string]fakeZone) *fakeDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv := &fakeDNSServer{
+		conn:    conn,
+		zones:   zones,
+		queries: map[string]int{},
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go srv.serve()
+	return srv
+}
+
+// resolver returns a net.Resolver that sends all its queries to the server
+func (s *fakeDNSServer) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.conn.LocalAddr().String())
+		},
+	}
+}
+
+// count returns the number of queries of type `qtype` received for `name`
+func (s *fakeDNSServer) count(name string, qtype uint16) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queries[queryKey(name, qtype)]
+}
+
+func queryKey(name string, qtype uint16) string {
+	return fmt.Sprintf("%s/%d", strings.ToLower(strings.TrimSuffix(name, ".")), qtype)
+}
+
+func (s *fakeDNSServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		if resp, ok := s.answer(buf[:n]); ok {
+			_, _ = s.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// answer builds the response for the DNS query message `msg`
+func (s *fakeDNSServer) answer(msg []byte) ([]byte, bool) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		return nil, false
+	}
+
+	// the question is a sequence of labels, followed by its type and class
+	var labels []string
+	off := 12
+	for off < len(msg) && msg[off] != 0 {
+		size := int(msg[off])
+		if off+1+size > len(msg) {
+			return nil, false
+		}
+		labels = append(labels, string(msg[off+1:off+1+size]))
+		off += 1 + size
+	}
+	off++
+	if off+4 > len(msg) {
+		return nil, false
+	}
+	question := msg[12 : off+4]
+	qtype := binary.BigEndian.Uint16(msg[off : off+2])
+	name := strings.ToLower(strings.Join(labels, "."))
+
+	s.mu.Lock()
+	s.queries[queryKey(name, qtype)]++
+	s.mu.Unlock()
+
+	zone, ok := s.zones[name]
+	if zone.silent {
+		return nil, false
+	}
+
+	var (
+		rcode   uint16 = dnsNoError
+		answers [][]byte
+	)
+	switch {
+	case !ok:
+		rcode = dnsNXDomain
+	case zone.servfail:
+		rcode = dnsServFail
+	case qtype == dnsTypeMX:
+		for idx, host := range zone.mx {
+			// a null MX (RFC 7505) has a preference of 0 and the root as its exchange
+			pref := uint16(10 * (idx + 1))
+			if host == "." {
+				pref = 0
+			}
+			rdata := binary.BigEndian.AppendUint16(nil, pref)
+			answers = append(answers, resourceRecord(dnsTypeMX, append(rdata, encodeName(host)...)))
+		}
+	case qtype == dnsTypeA:
+		for _, ip := range zone.a {
+			answers = append(answers, resourceRecord(dnsTypeA, ip.To4()))
+		}
+	}
+
+	// header: ID, flags (response, authoritative, recursion desired and available), counts
+	resp := make([]byte, 12, 512)
+	copy(resp[0:2], msg[0:2])
+	binary.BigEndian.PutUint16(resp[2:4], 0x8580|rcode)
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(answers)))
+
+	resp = append(resp, question...)
+	for _, rr := range answers {
+		resp = append(resp, rr...)
+	}
+
+	return resp, true
+}
+
+// resourceRecord encodes an answer for the question's name, with a pointer to it
+func resourceRecord(rtype uint16, rdata []byte) []byte {
+	rr := []byte{0xC0, 12}
+	rr = binary.BigEndian.AppendUint16(rr, rtype)
+	rr = binary.BigEndian.AppendUint16(rr, dnsClassIN)
+	rr = binary.BigEndian.AppendUint32(rr, 60)
+	rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+	return append(rr, rdata...)
+}
+
+func encodeName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
+
+func TestDNSValidator(t *testing.T) {
+	srv := newFakeDNSServer(t, map[string]fakeZone{
+		"mx.test":     {mx: []string{"mx1.mx.test", "mx2.mx.test"}},
+		"a.test":      {a: []net.IP{net.IPv4(192, 0, 2, 1)}},
+		"nullmx.test": {mx: []string{"."}, a: []net.IP{net.IPv4(192, 0, 2, 2)}},
+		"empty.test":  {},
+		"fail.test":   {servfail: true},
+		"slow.test":   {silent: true},
+	})
+	v := NewDNSValidator(srv.resolver(), 4, 300*time.Millisecond)
+
+	for _, testcase := range []struct {
+		domain string
+		wants  Deliverability
+	}{
+		{domain: "mx.test", wants: DeliverabilityMX},
+		{domain: "MX.test.", wants: DeliverabilityMX},
+		{domain: "a.test", wants: DeliverabilityAddress},
+		{domain: "nullmx.test", wants: DeliverabilityNullMX},
+		{domain: "empty.test", wants: DeliverabilityNone},
+		{domain: "nx.test", wants: DeliverabilityNone},
+		{domain: "fail.test", wants: DeliverabilityUnknown},
+		{domain: "slow.test", wants: DeliverabilityUnknown},
+		{domain: "[192.0.2.1]", wants: DeliverabilityUnknown},
+	} {
+		t.Run(testcase.domain, func(t *testing.T) {
+			if d := v.Check(context.Background(), testcase.domain); d != testcase.wants {
+				t.Errorf("output mismatch error: wanted %v ; got %v", testcase.wants, d)
+			}
+		})
+	}
+
+	t.Run("Cache", func(t *testing.T) {
+		mx, fail := srv.count("mx.test", dnsTypeMX), srv.count("fail.test", dnsTypeMX)
+
+		_ = v.Check(context.Background(), "mx.test")
+		_ = v.Check(context.Background(), "fail.test")
+
+		if n := srv.count("mx.test", dnsTypeMX); n != mx {
+			t.Errorf("expected a cached answer for mx.test: %d queries before ; %d after", mx, n)
+		}
+		if n := srv.count("fail.test", dnsTypeMX); n == fail {
+			t.Errorf("expected failed lookups not to be cached: %d queries before ; %d after", fail, n)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if d := NewDNSValidator(srv.resolver(), 1, time.Second).Check(ctx, "a.test"); d != DeliverabilityUnknown {
+			t.Errorf("output mismatch error: wanted %v ; got %v", DeliverabilityUnknown, d)
+		}
+	})
+}
+
+// slowResolver is a Resolver that answers every domain with an MX record after a delay,
+// tracking the number of concurrent lookups
+type slowResolver struct {
+	delay    time.Duration
+	inflight int32
+	peak     int32
+}
+
+func (r *slowResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	n := atomic.AddInt32(&r.inflight, 1)
+	defer atomic.AddInt32(&r.inflight, -1)
+
+	for {
+		peak := atomic.LoadInt32(&r.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&r.peak, peak, n) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(r.delay):
+		return []*net.MX{{Host: "mx." + name + ".", Pref: 10}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *slowResolver) LookupHost(context.Context, string) ([]string, error) {
+	return nil, errors.New("unexpected address lookup")
+}
+
+func TestDNSValidatorConcurrency(t *testing.T) {
+	r := &slowResolver{delay: 10 * time.Millisecond}
+	v := NewDNSValidator(r, 3, time.Second)
+
+	domains := make([]string, 20)
+	for idx := range domains {
+		domains[idx] = string(rune('a'+idx)) + ".test"
+	}
+
+	results := v.CheckAll(context.Background(), append(domains, domains[:5]...))
+	if len(results) != len(domains) {
+		t.Errorf("output length mismatch error: wanted %d ; got %d", len(domains), len(results))
+	}
+	for domain, d := range results {
+		if d != DeliverabilityMX {
+			t.Errorf("output mismatch error for %q: wanted %v ; got %v", domain, DeliverabilityMX, d)
+		}
+	}
+
+	if peak := atomic.LoadInt32(&r.peak); peak > 3 || peak < 2 {
+		t.Errorf("expected up to 3 concurrent lookups; got %d", peak)
+	}
+}
+
+func TestDNSValidation(t *testing.T) {
+	srv := newFakeDNSServer(t, map[string]fakeZone{
+		"mx.test": {mx: []string{"mx1.mx.test"}},
+		"a.test":  {a: []net.IP{net.IPv4(192, 0, 2, 1)}},
+	})
+
+	const input = `email
+a@mx.test
+b@mx.test
+c@a.test
+d@nx.test
+`
+
+	res, err := CountReader(strings.NewReader(input), WithDNSValidation(NewDNSValidator(srv.resolver(), 2, time.Second)))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	for domain, wants := range map[string]Deliverability{
+		"mx.test": DeliverabilityMX,
+		"a.test":  DeliverabilityAddress,
+		"nx.test": DeliverabilityNone,
+	} {
+		if e, ok := res.Lookup(domain); !ok || e.Deliverability != wants {
+			t.Errorf("deliverability mismatch for %q: wanted %v ; got %v", domain, wants, e)
+		}
+	}
+
+	if records := res.Records(); records[0].Deliverability != "address" {
+		t.Errorf("expected the records to include the deliverability; got %v", records[0])
+	}
+}
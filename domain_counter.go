@@ -6,96 +6,751 @@
 package customerimporter
 
 import (
-	"encoding/csv"
+	"context"
 	"errors"
-	"os"
-	"sort"
+	"io"
 	"strings"
 )
 
-const colName = "email"
-const colIdx = 2
-
 var (
 	ErrInvalidDomain   = errors.New("invalid domain name")
 	ErrInvalidColCount = errors.New("invalid number of columns")
+	ErrColumnNotFound  = errors.New("email column not found in CSV header")
 	ErrEmptySet        = errors.New("empty record set")
 	ErrExtracting      = errors.New("failed to extract email rows from CSV records")
 )
 
 // Entry describes a parsed domain from a CSV file. It contains the domain name and a count
 // for the number of customers with e-mail addresses for that same domain.
+//
+// When rolling up domains by their registrable domain (see WithRollup), Children lists the
+// counts for each of the original domains grouped in the Entry. With WithDisposableDomains,
+// Disposable reports whether the domain belongs to a disposable email provider, and with
+// WithClassifier, Category holds the kind of organization behind the domain. With WithAliases,
+// Aliases lists the counts for each of the alias domains folded into the Entry. With
+// WithDNSValidation, Deliverability reports whether the domain can receive email.
+//
+// Count is the number of rows for the domain. With WithDedup, Unique holds the number of
+// distinct addresses among them. With WithTopK, Count is an upper bound, exceeding the
+// domain's true count by at most Error.
 type Entry struct {
-	Count  int
-	Domain string
+	Count          int
+	Error          int
+	Unique         int
+	Domain         string
+	Children       []Entry
+	Aliases        []Entry
+	Disposable     bool
+	Category       Category
+	Deliverability Deliverability
 }
 
 // Parse reads a CSV file from `path` in the filesystem to extract the number of occurrences
-// for each present domain. Returns a slice of Entry and an error
-func Parse(path string) ([]Entry, error) {
-	records, err := parseCSV(path)
-	if err != nil {
-		return nil, err
+// for each present domain. The email column is located from the CSV header, unless
+// configured otherwise with the input Options. Returns a slice of Entry and an error
+func Parse(path string, opts ...Option) ([]Entry, error) {
+	return New(opts...).Parse(path)
+}
+
+// ParseReader reads CSV data from the io.Reader `r` to extract the number of occurrences
+// for each present domain. Records are consumed one at a time, so memory usage is bound
+// by the number of distinct domains rather than by the size of the input.
+// Returns a slice of Entry and an error
+func ParseReader(r io.Reader, opts ...Option) ([]Entry, error) {
+	return New(opts...).ParseReader(r)
+}
+
+// Count reads a CSV file from `path` in the filesystem to extract the number of occurrences
+// for each present domain. Returns a Result with the sorted entries and the parsing totals,
+// and an error
+func Count(path string, opts ...Option) (*Result, error) {
+	return New(opts...).Count(path)
+}
+
+// CountReader reads CSV data from the io.Reader `r` to extract the number of occurrences
+// for each present domain. Returns a Result with the sorted entries and the parsing totals,
+// and an error
+func CountReader(r io.Reader, opts ...Option) (*Result, error) {
+	return New(opts...).CountReader(r)
+}
+
+// ParseContext is like Parse, but stops once `ctx` is done, checking it between records. When
+// stopped, it returns the entries counted so far along with a CanceledError
+func ParseContext(ctx context.Context, path string, opts ...Option) ([]Entry, error) {
+	return New(opts...).ParseContext(ctx, path)
+}
+
+// ParseReaderContext is like ParseReader, but stops once `ctx` is done, checking it between
+// records. When stopped, it returns the entries counted so far along with a CanceledError
+func ParseReaderContext(ctx context.Context, r io.Reader, opts ...Option) ([]Entry, error) {
+	return New(opts...).ParseReaderContext(ctx, r)
+}
+
+// CountContext is like Count, but stops once `ctx` is done, checking it between records. When
+// stopped, it returns a Result with the totals counted so far along with a CanceledError
+func CountContext(ctx context.Context, path string, opts ...Option) (*Result, error) {
+	return New(opts...).CountContext(ctx, path)
+}
+
+// CountReaderContext is like CountReader, but stops once `ctx` is done, checking it between
+// records. When stopped, it returns a Result with the totals counted so far along with a
+// CanceledError
+func CountReaderContext(ctx context.Context, r io.Reader, opts ...Option) (*Result, error) {
+	return New(opts...).CountReaderContext(ctx, r)
+}
+
+// recordReader yields one CSV record per call, returning io.EOF once exhausted
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// findColumn returns the index of the first header cell matching one of `names`
+func findColumn(header []string, names []string) (int, error) {
+	for idx, cell := range header {
+		cell = strings.TrimSpace(cell)
+		if idx == 0 {
+			// exports from spreadsheet tools frequently start with a UTF-8 byte order mark
+			cell = strings.TrimPrefix(cell, "\uFEFF")
+		}
+
+		for _, name := range names {
+			if strings.EqualFold(cell, strings.TrimSpace(name)) {
+				return idx, nil
+			}
+		}
 	}
 
-	entryMap, err := mapEmailRow(records)
-	if err != nil {
-		return nil, err
+	return -1, ErrColumnNotFound
+}
+
+// fieldPositioner is implemented by record readers that can report the position of a
+// field in the input, such as csv.Reader and scanner
+type fieldPositioner interface {
+	FieldPos(field int) (line, column int)
+}
+
+// counter accumulates the domain counts, along with the totals reported in a Result,
+// while the records are read
+type counter struct {
+	cfg     config
+	domains map[string]int
+	// keys holds the counter's copy of each domain used as a map key (see own)
+	keys    map[string]string
+	skipped SkipReport
+
+	rows       int
+	duplicates int
+	// line is the line number of the last record read
+	line int
+	// seen holds the hashes of the addresses read so far, when tracking duplicates
+	seen map[uint64]struct{}
+	// occurrences holds the number of rows for each address hash, when deduplicating; it
+	// replaces seen, along with unique (the distinct addresses per domain) and repeated (the
+	// duplicated addresses, by hash)
+	occurrences map[uint64]int
+	unique      map[string]int
+	repeated    map[uint64]string
+	// origins holds the address and its counted domain for each hash in occurrences, when
+	// counting in a parallel worker, to merge the distinct addresses across workers (see merge)
+	origins map[uint64]origin
+	// sketch and sketches estimate the distinct addresses, globally and per domain, when
+	// deduplicating approximately
+	sketch   *Sketch
+	sketches map[string]*Sketch
+	// hitters tracks the most frequent domains instead of counting all of them in domains,
+	// when tracking the top K; errs holds the error for each of the top K domains
+	hitters *HeavyHitters
+	errs    map[string]int
+	// leftOut is the maximum count of any domain left out of the top K
+	leftOut int
+	// aliased holds the counts for each alias folded into a canonical domain, when using an
+	// AliasMap or correcting typos
+	aliased map[string]map[string]int
+	// typos holds the likely misspelled domains, when detecting typos
+	typos []Typo
+}
+
+func newCounter(cfg config) *counter {
+	c := &counter{
+		cfg:     cfg,
+		domains: map[string]int{},
+		keys:    map[string]string{},
+		aliased: map[string]map[string]int{},
+	}
+
+	switch {
+	case cfg.dedup:
+		c.occurrences = map[uint64]int{}
+		c.unique = map[string]int{}
+		c.repeated = map[uint64]string{}
+	case cfg.sketchPrecision > 0:
+		c.sketch = c.newSketch()
+		c.sketches = map[string]*Sketch{}
+	}
+
+	if cfg.topK > 0 {
+		c.hitters = NewHeavyHitters(cfg.topKCapacity)
 	}
 
-	return sortResults(entryMap), nil
+	return c
+}
+
+// newSketch returns an empty Sketch with the configured precision
+func (c *counter) newSketch() *Sketch {
+	// the precision is validated by WithApproximateDedup
+	s, _ := NewSketch(c.cfg.sketchPrecision)
+	return s
 }
 
-func parseCSV(path string) ([][]string, error) {
-	f, err := os.Open(path)
+// trackDuplicates enables counting the rows whose address was already seen. It is skipped when
+// tracking the top K, whose memory usage must not grow with the distinct addresses
+func (c *counter) trackDuplicates() {
+	if c.occurrences == nil && c.sketch == nil && c.hitters == nil {
+		c.seen = map[uint64]struct{}{}
+	}
+}
+
+// reject handles an invalid row according to the ErrorPolicy, returning a non-nil
+// error if parsing should stop
+func (c *counter) reject(rowErr *RowError) error {
+	if c.cfg.errorPolicy == FailFast {
+		return rowErr
+	}
+
+	c.skipped.add(rowErr, c.errorLimit())
+	return nil
+}
+
+// errorLimit returns the number of RowError to collect in the SkipReport
+func (c *counter) errorLimit() int {
+	if c.cfg.errorPolicy == SkipAndCollect {
+		return c.cfg.maxErrors
+	}
+	return 0
+}
+
+// add counts the domain in the email address `email`, returning an error if it is invalid
+func (c *counter) add(email string) error {
+	local, domain, err := SplitAddress(email, c.cfg.normalization)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if c.cfg.idna != IDNANone {
+		if domain, err = ToASCII(domain); err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.strict {
+		if err = ValidateAddress(local, domain); err != nil {
+			return err
+		}
+	}
+
+	var (
+		// the first row for each address, when deduplicating
+		first bool
+		h     uint64
+	)
+
+	switch {
+	case c.sketch != nil:
+		h = hashAddress(local, domain)
+		c.sketch.addHash(h)
+	case c.occurrences != nil:
+		h = hashAddress(local, domain)
+		n := c.occurrences[h] + 1
+		c.occurrences[h] = n
+
+		switch n {
+		case 1:
+			first = true
+		case 2:
+			c.repeated[h] = local + "@" + domain
+			fallthrough
+		default:
+			c.duplicates++
+		}
+	case c.seen != nil:
+		h = hashAddress(local, domain)
+		if _, ok := c.seen[h]; ok {
+			c.duplicates++
+		} else {
+			c.seen[h] = struct{}{}
+		}
+	}
+
+	// original is the domain before folding it into its canonical domain
+	original := domain
+	if c.cfg.aliases != nil {
+		if canonical, ok := c.cfg.aliases.Canonical(domain); ok {
+			c.fold(domain, canonical, 1)
+			domain = canonical
+		}
+	}
+
+	if c.hitters != nil {
+		// only the tracked domains keep a sketch, to keep the memory usage bounded
+		if evicted, ok := c.hitters.add(domain, 1); ok && c.sketches != nil {
+			delete(c.sketches, evicted)
+		}
+	}
+
+	switch {
+	case first:
+		c.unique[c.own(domain)]++
+		if c.origins != nil {
+			c.origins[h] = origin{address: local + "@" + original, domain: c.own(domain)}
+		}
+	case c.sketches != nil:
+		s, ok := c.sketches[domain]
+		if !ok {
+			s = c.newSketch()
+			c.sketches[c.own(domain)] = s
+		}
+		s.addHash(h)
+	}
+
+	if c.hitters != nil {
+		return nil
+	}
+
+	c.domains[c.own(domain)]++
+	return nil
+}
+
+// own returns the counter's copy of `s`, to use as a map key. Records may be views into the
+// reader's buffer, and assigning to an existing key in a map also replaces the stored key,
+// so keys are copied once per distinct value
+func (c *counter) own(s string) string {
+	if key, ok := c.keys[s]; ok {
+		return key
+	}
+
+	key := strings.Clone(s)
+	c.keys[key] = key
+	return key
+}
+
+// fold adds `n` to the count of the alias `domain` as folded into `canonical`
+func (c *counter) fold(domain, canonical string, n int) {
+	aliases, ok := c.aliased[canonical]
+	if !ok {
+		aliases = map[string]int{}
+		c.aliased[canonical] = aliases
 	}
 
-	r := csv.NewReader(f)
-	return r.ReadAll()
+	aliases[c.own(domain)] += n
 }
 
-func extractDomain(email string) (string, bool) {
-	for idx, c := range email {
-		if c == '@' {
-			return email[idx+1:], true
+// detectTypos finds the likely misspelled domains with the configured TypoDetector, merging
+// them into their suggested correction if enabled
+func (c *counter) detectTypos() {
+	c.typos = c.cfg.typos.Detect(c.domains)
+
+	if c.cfg.mergeTypos {
+		// typos are sorted by descending count, so a correction that is a typo itself is
+		// merged before any of the domains pointing to it
+		merged := make(map[string]string, len(c.typos))
+		for _, typo := range c.typos {
+			target := typo.Suggestion
+			for next, ok := merged[target]; ok; next, ok = merged[target] {
+				target = next
+			}
+
+			c.domains[target] += c.domains[typo.Domain]
+			delete(c.domains, typo.Domain)
+			if c.unique != nil {
+				c.unique[target] += c.unique[typo.Domain]
+				delete(c.unique, typo.Domain)
+			}
+			if c.sketches != nil {
+				c.mergeSketch(c.sketches, target, c.sketches[typo.Domain])
+				delete(c.sketches, typo.Domain)
+			}
+			if c.errs != nil {
+				c.errs[target] += c.errs[typo.Domain]
+				delete(c.errs, typo.Domain)
+			}
+			c.fold(typo.Domain, target, typo.Count)
+			merged[typo.Domain] = target
 		}
 	}
-	return "", false
+
+	for idx := range c.typos {
+		c.typos[idx].Domain = c.display(c.typos[idx].Domain)
+		c.typos[idx].Suggestion = c.display(c.typos[idx].Suggestion)
+	}
+}
+
+// column locates the email column in the records
+type column struct {
+	idx int
+	// name is the email column's header, to skip headers repeated in the input; it is empty
+	// if the input has no header
+	name string
+}
+
+// origin is the first row for an address in a parallel worker, with the domain it is counted in
+type origin struct {
+	address string
+	domain  string
 }
 
-func mapEmailRow(records [][]string) (map[string]int, error) {
-	if len(records) == 0 {
-		return nil, ErrEmptySet
+func (c *counter) mapEmailRow(ctx context.Context, r recordReader) error {
+	col, err := c.readHeader(r)
+	if err != nil {
+		return err
 	}
-	if len(records[0]) < 3 {
-		return nil, ErrInvalidColCount
+
+	start := 1
+	if c.cfg.noHeader {
+		start = 0
 	}
 
-	var entries = map[string]int{}
-	for _, r := range records {
-		// skip CSV header
-		if r[colIdx] == colName {
+	return c.mapRecords(ctx, r, col, start)
+}
+
+// readHeader reads the header from `r` to locate the email column, unless the input has no header
+func (c *counter) readHeader(r recordReader) (column, error) {
+	col := column{idx: c.cfg.columnIdx}
+
+	if !c.cfg.noHeader {
+		record, err := r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return col, ErrEmptySet
+			}
+			return col, err
+		}
+
+		if col.idx, err = findColumn(record, c.cfg.columnNames); err != nil {
+			return col, err
+		}
+		// records may be views into the reader's buffer, so the header is copied
+		col.name = strings.Clone(strings.TrimPrefix(strings.TrimSpace(record[col.idx]), "\uFEFF"))
+	}
+
+	if cs, ok := r.(columnSelector); ok {
+		cs.selectColumn(col.idx)
+	}
+
+	return col, nil
+}
+
+// mapRecords counts the domains in the email column for each record in `r`, where `start` is the
+// number of records already read from the input. It stops with a CanceledError once `ctx` is done
+func (c *counter) mapRecords(ctx context.Context, r recordReader, col column, start int) error {
+	fp, _ := r.(fieldPositioner)
+	done := ctx.Done()
+
+	for n := start; ; n++ {
+		if done != nil {
+			select {
+			case <-done:
+				return c.canceled(ctx)
+			default:
+			}
+		}
+
+		record, err := r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if n == 0 {
+					return ErrEmptySet
+				}
+				return nil
+			}
+
+			rowErr, ok := newParseRowError(err)
+			if !ok || n == 0 {
+				return err
+			}
+			c.line = rowErr.Line
+			c.rows++
+			if err = c.reject(rowErr); err != nil {
+				return err
+			}
 			continue
 		}
 
-		domain, ok := extractDomain(r[colIdx])
-		if !ok {
-			return nil, ErrInvalidDomain
+		line := n + 1
+		if fp != nil {
+			line, _ = fp.FieldPos(0)
 		}
-		if count, ok := entries[domain]; ok {
-			entries[domain] = count + 1
+		c.line = line
+
+		if col.idx >= len(record) {
+			c.rows++
+			if err = c.reject(&RowError{Line: line, Err: ErrInvalidColCount}); err != nil {
+				return err
+			}
 			continue
 		}
 
-		entries[domain] = 1
+		// skip CSV headers repeated in concatenated exports
+		if col.name != "" && strings.EqualFold(strings.TrimSpace(record[col.idx]), col.name) {
+			continue
+		}
+
+		c.rows++
+		if err = c.add(record[col.idx]); err != nil {
+			if err = c.reject(&RowError{Line: line, Value: strings.Clone(record[col.idx]), Err: err}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entries returns the counted domains as a slice of Entry, in the configured SortOrder and IDNAForm,
+// after detecting (and merging) typos if enabled. Domains are only checked with the DNSValidator
+// while `ctx` is not done
+func (c *counter) entries(ctx context.Context) []Entry {
+	if c.hitters != nil {
+		c.topDomains()
+	}
+	if c.cfg.typos != nil {
+		c.detectTypos()
+	}
+
+	domains := c.domains
+
+	if c.cfg.idna == IDNAUnicode {
+		domains = make(map[string]int, len(c.domains))
+		for domain, count := range c.domains {
+			domains[c.display(domain)] += count
+		}
+	}
+
+	var entries []Entry
+	if c.cfg.rollup != nil {
+		entries = rollupResults(domains, c.cfg.rollup, c.cfg.sortOrder)
+	} else {
+		entries = sortResults(domains, c.cfg.sortOrder)
+	}
+
+	if c.cfg.disposable != nil {
+		tagDisposable(entries, c.cfg.disposable)
+	}
+	if c.cfg.classifier != nil {
+		classifyEntries(entries, c.cfg.classifier)
+	}
+	if c.sketches != nil {
+		c.sketches = c.displaySketches()
+
+		c.unique = make(map[string]int, len(c.sketches))
+		for domain, s := range c.sketches {
+			c.unique[domain] = int(s.Estimate())
+		}
+	}
+	if c.unique != nil {
+		unique := c.unique
+		if c.cfg.idna == IDNAUnicode && c.sketches == nil {
+			unique = make(map[string]int, len(c.unique))
+			for domain, count := range c.unique {
+				unique[c.display(domain)] += count
+			}
+		}
+
+		attachUnique(entries, unique)
+	}
+	if c.errs != nil {
+		errs := c.errs
+		if c.cfg.idna == IDNAUnicode {
+			errs = make(map[string]int, len(c.errs))
+			for domain, e := range c.errs {
+				errs[c.display(domain)] += e
+			}
+		}
+
+		attachErrors(entries, errs)
+	}
+	if len(c.aliased) > 0 {
+		attachAliases(entries, c.aliasEntries())
+	}
+	if c.cfg.dns != nil && ctx.Err() == nil {
+		c.cfg.dns.Annotate(ctx, entries)
+	}
+
+	return entries
+}
+
+// display returns `domain` in the configured IDNAForm
+func (c *counter) display(domain string) string {
+	if c.cfg.idna == IDNAUnicode {
+		if u, err := ToUnicode(domain); err == nil {
+			return u
+		}
+	}
+
+	return domain
+}
+
+// aliasEntries returns the folded aliases for each canonical domain, in the configured
+// SortOrder and IDNAForm
+func (c *counter) aliasEntries() map[string][]Entry {
+	output := make(map[string][]Entry, len(c.aliased))
+	for canonical, aliases := range c.aliased {
+		entries := make([]Entry, 0, len(aliases))
+		for alias, count := range aliases {
+			entries = append(entries, Entry{
+				Count:  count,
+				Domain: c.display(alias),
+			})
+		}
+
+		SortEntries(entries, c.cfg.sortOrder)
+		output[c.display(canonical)] = entries
+	}
+
+	return output
+}
+
+// mergeSketch merges the Sketch `s` into the one for `domain` in `sketches`
+func (c *counter) mergeSketch(sketches map[string]*Sketch, domain string, s *Sketch) {
+	if s == nil {
+		return
+	}
+
+	target, ok := sketches[domain]
+	if !ok {
+		target = c.newSketch()
+		sketches[domain] = target
+	}
+	// sketches in a counter share the same precision
+	_ = target.Merge(s)
+}
+
+// displaySketches returns the per-domain sketches keyed by the domains in the configured IDNAForm
+func (c *counter) displaySketches() map[string]*Sketch {
+	if c.cfg.idna != IDNAUnicode {
+		return c.sketches
+	}
+
+	sketches := make(map[string]*Sketch, len(c.sketches))
+	for domain, s := range c.sketches {
+		c.mergeSketch(sketches, c.display(domain), s)
 	}
 
-	return entries, nil
+	return sketches
 }
 
-func sortResults(results map[string]int) []Entry {
+// topDomains replaces the domain counts with the top K domains tracked by the HeavyHitters
+func (c *counter) topDomains() {
+	top, leftOut := c.hitters.top(c.cfg.topK)
+	c.leftOut = leftOut
+
+	c.domains = make(map[string]int, len(top))
+	c.errs = make(map[string]int, len(top))
+	for _, hitter := range top {
+		c.domains[hitter.Item] = hitter.Count
+		c.errs[hitter.Item] = hitter.Error
+	}
+
+	for domain := range c.sketches {
+		if _, ok := c.domains[domain]; !ok {
+			delete(c.sketches, domain)
+		}
+	}
+}
+
+// attachErrors sets the count error of the entries (and their children) from `errs`, returning
+// their sum. Rolled-up entries add up the errors of their children
+func attachErrors(entries []Entry, errs map[string]int) int {
+	var total int
+	for idx := range entries {
+		if len(entries[idx].Children) > 0 {
+			entries[idx].Error = attachErrors(entries[idx].Children, errs)
+		} else {
+			entries[idx].Error = errs[entries[idx].Domain]
+		}
+		total += entries[idx].Error
+	}
+
+	return total
+}
+
+// attachUnique sets the distinct address count of the entries (and their children) from
+// `unique`, returning their sum. Rolled-up entries add up the counts of their children
+func attachUnique(entries []Entry, unique map[string]int) int {
+	var total int
+	for idx := range entries {
+		if len(entries[idx].Children) > 0 {
+			entries[idx].Unique = attachUnique(entries[idx].Children, unique)
+		} else {
+			entries[idx].Unique = unique[entries[idx].Domain]
+		}
+		total += entries[idx].Unique
+	}
+
+	return total
+}
+
+// duplicateAddresses returns the addresses found in more than one row, sorted by descending count
+func (c *counter) duplicateAddresses() []AddressCount {
+	if len(c.repeated) == 0 {
+		return nil
+	}
+
+	addresses := make([]AddressCount, 0, len(c.repeated))
+	for h, address := range c.repeated {
+		addresses = append(addresses, AddressCount{
+			Address: address,
+			Count:   c.occurrences[h],
+		})
+	}
+
+	sortAddressCounts(addresses)
+	return addresses
+}
+
+// attachAliases sets the folded aliases of the entries (and their children) from `aliases`
+func attachAliases(entries []Entry, aliases map[string][]Entry) {
+	for idx := range entries {
+		entries[idx].Aliases = aliases[entries[idx].Domain]
+		attachAliases(entries[idx].Children, aliases)
+	}
+}
+
+// tagDisposable flags the entries (and their children) in the DomainList `list`
+func tagDisposable(entries []Entry, list *DomainList) {
+	for idx := range entries {
+		entries[idx].Disposable = list.Contains(entries[idx].Domain)
+		tagDisposable(entries[idx].Children, list)
+	}
+}
+
+// classifyEntries sets the Category of the entries (and their children) with the Classifier `cl`
+func classifyEntries(entries []Entry, cl *Classifier) {
+	for idx := range entries {
+		entries[idx].Category = cl.Classify(entries[idx].Domain)
+		classifyEntries(entries[idx].Children, cl)
+	}
+}
+
+// hashAddress returns the 64-bit FNV-1a hash of the address `local`@`domain`, without allocating
+func hashAddress(local, domain string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for i := 0; i < len(local); i++ {
+		h ^= uint64(local[i])
+		h *= prime64
+	}
+	h ^= uint64('@')
+	h *= prime64
+	for i := 0; i < len(domain); i++ {
+		h ^= uint64(domain[i])
+		h *= prime64
+	}
+	return h
+}
+
+func sortResults(results map[string]int, order SortOrder) []Entry {
 	var (
 		output = make([]Entry, len(results))
 		idx    = 0
@@ -109,14 +764,43 @@ func sortResults(results map[string]int) []Entry {
 		idx++
 	}
 
-	sort.Slice(output, func(i, j int) bool {
-		switch strings.Compare(output[i].Domain, output[j].Domain) {
-		case -1:
-			return true
-		default:
-			return false
+	SortEntries(output, order)
+
+	return output
+}
+
+// rollupResults groups the domains in `results` by their registrable domain, according to
+// the SuffixList `list`. Domains that are public suffixes themselves are kept as they are
+func rollupResults(results map[string]int, list *SuffixList, order SortOrder) []Entry {
+	groups := make(map[string][]Entry, len(results))
+	for domain, count := range results {
+		registrable, err := list.RegistrableDomain(domain)
+		if err != nil {
+			registrable = domain
 		}
-	})
+
+		groups[registrable] = append(groups[registrable], Entry{
+			Count:  count,
+			Domain: domain,
+		})
+	}
+
+	output := make([]Entry, 0, len(groups))
+	for registrable, children := range groups {
+		var total int
+		for _, child := range children {
+			total += child.Count
+		}
+
+		SortEntries(children, order)
+		output = append(output, Entry{
+			Count:    total,
+			Domain:   registrable,
+			Children: children,
+		})
+	}
+
+	SortEntries(output, order)
 
 	return output
 }
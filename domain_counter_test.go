@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	. "github.com/zalgonoise/emailimp"
@@ -73,6 +74,118 @@ func TestParse(t *testing.T) {
 	})
 }
 
+func TestParseReader(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		f, err := os.Open(rawPath)
+		if err != nil {
+			t.Errorf("failed to open test file: %v", err)
+			return
+		}
+		defer f.Close()
+
+		entries, err := ParseReader(f)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if len(entries) != len(expectedResults) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d", len(expectedResults), len(entries))
+		}
+
+		for _, e := range entries {
+			if expectedResults[e.Domain] != e.Count {
+				t.Errorf("output mismatch error: expected domain %s to have %d users ; has %d", e.Domain, expectedResults[e.Domain], e.Count)
+			}
+		}
+	})
+
+	t.Run("ColumnDetection", func(t *testing.T) {
+		for _, testcase := range []struct {
+			name  string
+			input string
+			opts  []Option
+		}{
+			{
+				name:  "FirstColumn",
+				input: "email,name\njohn@example.com,john\njane@example.com,jane\n",
+			},
+			{
+				name:  "CaseInsensitiveAlias",
+				input: "Name,Email Address\njohn,john@example.com\njane,jane@example.com\n",
+			},
+			{
+				name:  "ByteOrderMark",
+				input: "\uFEFFE-Mail,name\njohn@example.com,john\njane@example.com,jane\n",
+			},
+			{
+				name:  "CustomColumnName",
+				input: "nombre,correo\njohn,john@example.com\njane,jane@example.com\n",
+				opts:  []Option{WithColumnNames("Correo")},
+			},
+			{
+				name:  "Headerless",
+				input: "john,john@example.com\njane,jane@example.com\n",
+				opts:  []Option{WithColumnIndex(1)},
+			},
+		} {
+			t.Run(testcase.name, func(t *testing.T) {
+				entries, err := ParseReader(strings.NewReader(testcase.input), testcase.opts...)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				if len(entries) != 1 || entries[0].Domain != "example.com" || entries[0].Count != 2 {
+					t.Errorf("output mismatch error: wanted [{2 example.com}] ; got %v", entries)
+				}
+			})
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		for _, testcase := range []struct {
+			name  string
+			input string
+			opts  []Option
+			err   error
+		}{
+			{
+				name:  "EmptySet",
+				input: "",
+				err:   ErrEmptySet,
+			},
+			{
+				name:  "ColumnNotFound",
+				input: "name,address\njohn,john@example.com\n",
+				err:   ErrColumnNotFound,
+			},
+			{
+				name:  "InvalidColCount",
+				input: "john,john@example.com\n",
+				opts:  []Option{WithColumnIndex(2)},
+				err:   ErrInvalidColCount,
+			},
+			{
+				name:  "InvalidDomain",
+				input: "first_name,last_name,email\njohn,doe,johnexample.com\n",
+				err:   ErrInvalidDomain,
+			},
+		} {
+			t.Run(testcase.name, func(t *testing.T) {
+				_, err := ParseReader(strings.NewReader(testcase.input), testcase.opts...)
+				if err == nil {
+					t.Error("expected an error; got nil")
+					return
+				}
+				if !errors.Is(err, testcase.err) {
+					t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+					return
+				}
+			})
+		}
+	})
+}
+
 var expectedResults = map[string]int{
 	"123-reg.co.uk":          8,
 	"163.com":                6,
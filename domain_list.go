@@ -0,0 +1,124 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed data/disposable_domains.txt
+var embeddedDisposableDomains []byte
+
+var (
+	defaultDisposable     *DomainList
+	defaultDisposableOnce sync.Once
+)
+
+// DomainList is a set of domain names, matched along with their subdomains. It is safe for
+// concurrent reads, but not for concurrent calls to Add
+type DomainList struct {
+	domains map[string]struct{}
+}
+
+// NewDomainList creates a DomainList with the input `domains`
+func NewDomainList(domains ...string) *DomainList {
+	l := &DomainList{domains: make(map[string]struct{}, len(domains))}
+	l.Add(domains...)
+
+	return l
+}
+
+// LoadDomainList parses a DomainList from `r`, with one domain per line. Blank lines and lines
+// starting with `#` are ignored
+func LoadDomainList(r io.Reader) (*DomainList, error) {
+	l := NewDomainList()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		l.Add(line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// DefaultDisposableDomains returns a copy of the list of disposable email providers embedded
+// in the package, which can be extended with Add
+func DefaultDisposableDomains() *DomainList {
+	defaultDisposableOnce.Do(func() {
+		list, err := LoadDomainList(bytes.NewReader(embeddedDisposableDomains))
+		if err != nil {
+			panic(fmt.Sprintf("customerimporter: invalid embedded disposable domain list: %v", err))
+		}
+		defaultDisposable = list
+	})
+
+	return defaultDisposable.Clone()
+}
+
+// Add inserts the input `domains` in the DomainList, in their canonical (lowercase, ASCII) form
+func (l *DomainList) Add(domains ...string) {
+	for _, domain := range domains {
+		if domain = canonicalDomain(domain); domain == "" {
+			continue
+		}
+		l.domains[domain] = struct{}{}
+	}
+}
+
+// Clone returns a copy of the DomainList
+func (l *DomainList) Clone() *DomainList {
+	c := &DomainList{domains: make(map[string]struct{}, len(l.domains))}
+	for domain := range l.domains {
+		c.domains[domain] = struct{}{}
+	}
+
+	return c
+}
+
+// Merge adds all domains in `other` to the DomainList
+func (l *DomainList) Merge(other *DomainList) {
+	for domain := range other.domains {
+		l.domains[domain] = struct{}{}
+	}
+}
+
+// Len returns the number of domains in the DomainList
+func (l *DomainList) Len() int {
+	return len(l.domains)
+}
+
+// Contains reports whether `domain`, or any of its parent domains, is in the DomainList.
+// For example, a list with `mailinator.com` contains `eu.mailinator.com`
+func (l *DomainList) Contains(domain string) bool {
+	if !isASCII(domain) {
+		if ascii, err := ToASCII(domain); err == nil {
+			domain = ascii
+		}
+	}
+	domain = strings.ToLower(domain)
+
+	for {
+		if _, ok := l.domains[domain]; ok {
+			return true
+		}
+
+		idx := strings.IndexByte(domain, '.')
+		if idx < 0 {
+			return false
+		}
+		domain = domain[idx+1:]
+	}
+}
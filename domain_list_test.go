@@ -0,0 +1,123 @@
+package customerimporter_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestDomainList(t *testing.T) {
+	list := DefaultDisposableDomains()
+	list.Add("Throwaway.Example.")
+
+	for _, testcase := range []struct {
+		domain string
+		wants  bool
+	}{
+		{domain: "mailinator.com", wants: true},
+		{domain: "MAILINATOR.COM", wants: true},
+		{domain: "eu.mailinator.com", wants: true},
+		{domain: "yopmail.fr", wants: true},
+		{domain: "throwaway.example", wants: true},
+		{domain: "a.throwaway.example", wants: true},
+		{domain: "gmail.com", wants: false},
+		{domain: "notmailinator.com", wants: false},
+		{domain: "com", wants: false},
+	} {
+		t.Run(testcase.domain, func(t *testing.T) {
+			if ok := list.Contains(testcase.domain); ok != testcase.wants {
+				t.Errorf("output mismatch error: wanted %v ; got %v", testcase.wants, ok)
+			}
+		})
+	}
+
+	t.Run("DefaultIsCopied", func(t *testing.T) {
+		if DefaultDisposableDomains().Contains("throwaway.example") {
+			t.Errorf("expected changes to a default list not to leak into other copies")
+		}
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		list, err := LoadDomainList(strings.NewReader("# custom list\n\nburner.test\n  bücher.example  \n"))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if list.Len() != 2 {
+			t.Errorf("domain count mismatch: wanted %d ; got %d", 2, list.Len())
+		}
+		for _, domain := range []string{"burner.test", "xn--bcher-kva.example", "bücher.example"} {
+			if !list.Contains(domain) {
+				t.Errorf("expected %q to be in the list", domain)
+			}
+		}
+	})
+}
+
+func TestDisposable(t *testing.T) {
+	const input = `email
+a@gmail.com
+b@gmail.com
+c@mailinator.com
+d@yopmail.com
+e@company.com
+f@temp.company.com
+g@company.com
+h@gmail.com
+`
+
+	list := DefaultDisposableDomains()
+	list.Add("temp.company.com")
+
+	t.Run("Flat", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input), WithDisposableDomains(list))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		for domain, wants := range map[string]bool{
+			"gmail.com":        false,
+			"mailinator.com":   true,
+			"yopmail.com":      true,
+			"company.com":      false,
+			"temp.company.com": true,
+		} {
+			if e, ok := res.Lookup(domain); !ok || e.Disposable != wants {
+				t.Errorf("disposable flag mismatch for %q: wanted %v ; got %v", domain, wants, e)
+			}
+		}
+
+		wants := DisposableSummary{Domains: 3, Count: 3, Share: 37.5}
+		if summary := res.Disposable(); summary != wants {
+			t.Errorf("output mismatch error: wanted %+v ; got %+v", wants, summary)
+		}
+	})
+
+	t.Run("Rollup", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input), WithDisposableDomains(list), WithRollup(nil))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		wants := DisposableSummary{Domains: 3, Count: 3, Share: 37.5}
+		if summary := res.Disposable(); summary != wants {
+			t.Errorf("output mismatch error: wanted %+v ; got %+v", wants, summary)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if summary := res.Disposable(); summary.Count != 0 {
+			t.Errorf("expected no disposable domains without WithDisposableDomains; got %+v", summary)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package customerimporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var ErrInvalidFormat = errors.New("invalid output format")
+
+// Format defines how a Result is encoded by Result.Encode
+type Format uint8
+
+const (
+	// FormatText writes an aligned plain text table. This is the default
+	FormatText Format = iota
+	// FormatJSON writes a JSON array of Record
+	FormatJSON
+	// FormatNDJSON writes one JSON-encoded Record per line
+	FormatNDJSON
+	// FormatCSV writes comma-separated values, with a header row
+	FormatCSV
+	// FormatTSV writes tab-separated values, with a header row
+	FormatTSV
+	// FormatMarkdown writes a Markdown table
+	FormatMarkdown
+	// FormatTree writes the domain label tree (see Result.Tree), indented by depth
+	FormatTree
+	// FormatTreeJSON writes the domain label tree (see Result.Tree) as a JSON object
+	FormatTreeJSON
+)
+
+var formatNames = map[Format]string{
+	FormatText:     "text",
+	FormatJSON:     "json",
+	FormatNDJSON:   "ndjson",
+	FormatCSV:      "csv",
+	FormatTSV:      "tsv",
+	FormatMarkdown: "markdown",
+	FormatTree:     "tree",
+	FormatTreeJSON: "tree-json",
+}
+
+var formatAliases = map[string]Format{
+	"txt":   FormatText,
+	"jsonl": FormatNDJSON,
+	"md":    FormatMarkdown,
+}
+
+// recordFields lists the (stable) field names in the output schema, in order
+var recordFields = []string{"domain", "count", "share"}
+
+// boundedRecordFields lists the field names in the output schema when counts are upper bounds
+// (see WithTopK), in order
+var boundedRecordFields = []string{"domain", "count", "error", "share"}
+
+// String implements the fmt.Stringer interface
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", f)
+}
+
+// ParseFormat returns the Format for `name`, one of `text` (or `txt`), `json`, `ndjson`
+// (or `jsonl`), `csv`, `tsv`, `markdown` (or `md`), `tree` and `tree-json`
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	if f, ok := formatAliases[name]; ok {
+		return f, nil
+	}
+
+	return FormatText, fmt.Errorf("%w: %q", ErrInvalidFormat, name)
+}
+
+// Record is the output schema for an Entry, as encoded by Result.Encode. Error, Unique,
+// Disposable, Category, Deliverability, Aliases and Children are only present when enabled
+// (see WithTopK, WithDedup, WithDisposableDomains, WithClassifier, WithDNSValidation,
+// WithAliases and WithRollup), and only in the JSON formats
+type Record struct {
+	Domain         string   `json:"domain"`
+	Count          int      `json:"count"`
+	Error          int      `json:"error,omitempty"`
+	Unique         int      `json:"unique,omitempty"`
+	Share          float64  `json:"share"`
+	Disposable     bool     `json:"disposable,omitempty"`
+	Category       string   `json:"category,omitempty"`
+	Deliverability string   `json:"deliverability,omitempty"`
+	Aliases        []Record `json:"aliases,omitempty"`
+	Children       []Record `json:"children,omitempty"`
+}
+
+// Records converts the Result's entries into Records, in the same order. The share is the
+// percentage of the total count, rounded to 4 decimal places; with WithTopK, the total only
+// covers the top K domains
+func (r *Result) Records() []Record {
+	return newRecords(r.Entries, r.Total())
+}
+
+func newRecords(entries []Entry, total int) []Record {
+	records := make([]Record, len(entries))
+
+	for idx, e := range entries {
+		records[idx] = Record{
+			Domain:     e.Domain,
+			Count:      e.Count,
+			Error:      e.Error,
+			Unique:     e.Unique,
+			Disposable: e.Disposable,
+		}
+		if e.Category != CategoryUnknown {
+			records[idx].Category = e.Category.String()
+		}
+		if e.Deliverability != DeliverabilityUnchecked {
+			records[idx].Deliverability = e.Deliverability.String()
+		}
+		records[idx].Share = percentage(e.Count, total)
+		if len(e.Aliases) > 0 {
+			records[idx].Aliases = newRecords(e.Aliases, total)
+		}
+		if len(e.Children) > 0 {
+			records[idx].Children = newRecords(e.Children, total)
+		}
+	}
+
+	return records
+}
+
+// Encode writes the Result's entries to `w` in the Format `f`
+func (r *Result) Encode(w io.Writer, f Format) error {
+	bw := bufio.NewWriter(w)
+
+	var err error
+	switch f {
+	case FormatTree:
+		err = r.Tree().Fprint(bw)
+	case FormatTreeJSON:
+		err = encodeJSON(bw, r.Tree())
+	default:
+		header, row := recordFields, Record.row
+		if r.overestimated() {
+			header, row = boundedRecordFields, Record.boundedRow
+		}
+		err = encodeRecords(bw, f, header, r.Records(), row)
+	}
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// row returns the Record's fields as strings, in the order of recordFields
+func (rec Record) row() []string {
+	return []string{rec.Domain, strconv.Itoa(rec.Count), formatShare(rec.Share)}
+}
+
+// boundedRow returns the Record's fields as strings, in the order of boundedRecordFields
+func (rec Record) boundedRow() []string {
+	return []string{rec.Domain, strconv.Itoa(rec.Count), strconv.Itoa(rec.Error), formatShare(rec.Share)}
+}
+
+// overestimated reports whether any entry's count may exceed its true count (see WithTopK)
+func (r *Result) overestimated() bool {
+	for _, e := range r.Entries {
+		if e.Error > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func formatShare(share float64) string {
+	return strconv.FormatFloat(share, 'f', 4, 64)
+}
+
+// encodeRecords writes `records` to `w` in the Format `f`: the JSON formats encode the records
+// as they are, while the tabular formats write a `header` row followed by each record's `row`
+func encodeRecords[T any](w io.Writer, f Format, header []string, records []T, row func(T) []string) error {
+	switch f {
+	case FormatJSON:
+		return encodeJSON(w, records)
+	case FormatNDJSON:
+		return encodeNDJSON(w, records)
+	case FormatText, FormatCSV, FormatTSV, FormatMarkdown:
+		rows := make([][]string, len(records))
+		for idx := range records {
+			rows[idx] = row(records[idx])
+		}
+
+		switch f {
+		case FormatCSV:
+			return encodeSeparated(w, header, rows, ',')
+		case FormatTSV:
+			return encodeSeparated(w, header, rows, '\t')
+		case FormatMarkdown:
+			return encodeMarkdown(w, header, rows)
+		default:
+			return encodeText(w, header, rows)
+		}
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidFormat, f)
+	}
+}
+
+func encodeText(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+func encodeNDJSON[T any](w io.Writer, records []T) error {
+	enc := json.NewEncoder(w)
+
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeSeparated(w io.Writer, header []string, rows [][]string, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+func encodeMarkdown(w io.Writer, header []string, rows [][]string) error {
+	// the first column is left-aligned, the remaining ones are right-aligned
+	align := make([]string, len(header))
+	for idx := range align {
+		align[idx] = "---:"
+	}
+	align[0] = "---"
+
+	if _, err := fmt.Fprintf(w, "| %s |\n| %s |\n", strings.Join(header, " | "), strings.Join(align, " | ")); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for idx, cell := range row {
+			// pipes are not valid in domain names, but are escaped to keep the table intact
+			cells[idx] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package customerimporter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+const formatInput = `email
+john@example.com
+jane@example.com
+jim@example.com
+joe@example.org
+`
+
+func TestEncode(t *testing.T) {
+	res, err := CountReader(strings.NewReader(formatInput), WithSort(SortByCountDesc))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	for _, testcase := range []struct {
+		format Format
+		wants  string
+	}{
+		{
+			format: FormatText,
+			wants: "domain       count  share\n" +
+				"example.com  3      75.0000\n" +
+				"example.org  1      25.0000\n",
+		},
+		{
+			format: FormatNDJSON,
+			wants: `{"domain":"example.com","count":3,"share":75}` + "\n" +
+				`{"domain":"example.org","count":1,"share":25}` + "\n",
+		},
+		{
+			format: FormatCSV,
+			wants:  "domain,count,share\nexample.com,3,75.0000\nexample.org,1,25.0000\n",
+		},
+		{
+			format: FormatTSV,
+			wants:  "domain\tcount\tshare\nexample.com\t3\t75.0000\nexample.org\t1\t25.0000\n",
+		},
+		{
+			format: FormatMarkdown,
+			wants: "| domain | count | share |\n| --- | ---: | ---: |\n" +
+				"| example.com | 3 | 75.0000 |\n| example.org | 1 | 25.0000 |\n",
+		},
+	} {
+		t.Run(testcase.format.String(), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := res.Encode(buf, testcase.format); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if buf.String() != testcase.wants {
+				t.Errorf("output mismatch error: wanted %q ; got %q", testcase.wants, buf.String())
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := res.Encode(buf, FormatJSON); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		var records []Record
+		if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+			t.Errorf("failed to decode JSON output: %v", err)
+			return
+		}
+
+		wants := res.Records()
+		if len(records) != len(wants) || records[0].Domain != wants[0].Domain || records[1].Share != wants[1].Share {
+			t.Errorf("output mismatch error: wanted %v ; got %v", wants, records)
+		}
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		if err := res.Encode(&bytes.Buffer{}, Format(255)); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidFormat, err)
+		}
+		if _, err := ParseFormat("xml"); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidFormat, err)
+		}
+	})
+}
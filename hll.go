@@ -0,0 +1,339 @@
+package customerimporter
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// MinPrecision and MaxPrecision bound the precision of a Sketch, as the number of bits
+	// indexing its registers
+	MinPrecision = 4
+	MaxPrecision = 18
+
+	// sketchVersion is the version of the binary encoding of a Sketch
+	sketchVersion = 1
+
+	sketchDense  = 0
+	sketchSparse = 1
+
+	// defaultErrorBound is the relative standard error used by WithApproximateDedup on invalid bounds
+	defaultErrorBound = 0.01
+)
+
+var (
+	ErrInvalidPrecision  = errors.New("invalid sketch precision")
+	ErrInvalidErrorBound = errors.New("invalid sketch error bound")
+	ErrSketchMismatch    = errors.New("sketches have different precisions")
+	ErrInvalidSketch     = errors.New("invalid sketch encoding")
+)
+
+// Sketch is a HyperLogLog sketch, estimating the number of distinct items added to it with
+// a fixed amount of memory: 2^precision bytes, with a relative standard error of about
+// 1.04 / sqrt(2^precision). Small sketches use a sparse representation until they fill
+// 1/16th of their registers.
+//
+// Sketches with the same precision can be merged, and are serialised with MarshalBinary (or
+// MarshalText, as base64), so the estimates from separate runs can be combined. A Sketch is
+// not safe for concurrent use
+type Sketch struct {
+	precision uint8
+	registers []uint8
+	// sparse holds the non-zero registers, by index, until the Sketch becomes dense
+	sparse map[uint32]uint8
+}
+
+// NewSketch creates an empty Sketch with the input `precision`, from MinPrecision to MaxPrecision
+func NewSketch(precision uint8) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPrecision, precision)
+	}
+
+	return &Sketch{
+		precision: precision,
+		sparse:    map[uint32]uint8{},
+	}, nil
+}
+
+// PrecisionForError returns the lowest precision for a Sketch with a relative standard error
+// up to `bound`, such as 0.01 for 1%
+func PrecisionForError(bound float64) (uint8, error) {
+	if !(bound > 0 && bound < 1) {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidErrorBound, bound)
+	}
+
+	p := math.Ceil(math.Log2(math.Pow(1.04/bound, 2)))
+	switch {
+	case p < MinPrecision:
+		return MinPrecision, nil
+	case p > MaxPrecision:
+		return 0, fmt.Errorf("%w: %v requires more than %d bits of precision", ErrInvalidErrorBound, bound, MaxPrecision)
+	default:
+		return uint8(p), nil
+	}
+}
+
+// Precision returns the Sketch's precision
+func (s *Sketch) Precision() uint8 {
+	return s.precision
+}
+
+// RelativeError returns the relative standard error of the Sketch's estimates
+func (s *Sketch) RelativeError() float64 {
+	return 1.04 / math.Sqrt(float64(uint32(1)<<s.precision))
+}
+
+// Add inserts `item`, such as an email address, in the Sketch
+func (s *Sketch) Add(item string) {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for i := 0; i < len(item); i++ {
+		h ^= uint64(item[i])
+		h *= prime64
+	}
+	s.addHash(h)
+}
+
+// addHash inserts an item by its 64-bit FNV-1a hash, as computed by Add (or hashAddress)
+func (s *Sketch) addHash(h uint64) {
+	// FNV-1a does not spread its bits evenly enough for HyperLogLog, so the hash is finalized
+	// with the mixing steps from MurmurHash3
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	idx := uint32(h >> (64 - s.precision))
+	// the rank is the position of the first set bit after the index bits, capped by a
+	// sentinel bit for hashes where all the remaining bits are zero
+	rank := uint8(bits.LeadingZeros64(h<<s.precision|1<<(s.precision-1))) + 1
+
+	s.set(idx, rank)
+}
+
+// set raises the register at `idx` to `rank`
+func (s *Sketch) set(idx uint32, rank uint8) {
+	if s.registers != nil {
+		if rank > s.registers[idx] {
+			s.registers[idx] = rank
+		}
+		return
+	}
+
+	if rank > s.sparse[idx] {
+		s.sparse[idx] = rank
+		if len(s.sparse) > (1<<s.precision)/16 {
+			s.densify()
+		}
+	}
+}
+
+// densify switches the Sketch from the sparse to the dense representation
+func (s *Sketch) densify() {
+	s.registers = make([]uint8, 1<<s.precision)
+	for idx, rank := range s.sparse {
+		s.registers[idx] = rank
+	}
+	s.sparse = nil
+}
+
+// Estimate returns the estimated number of distinct items added to the Sketch
+func (s *Sketch) Estimate() uint64 {
+	m := float64(uint32(1) << s.precision)
+
+	var (
+		sum   float64
+		zeros int
+	)
+	if s.registers != nil {
+		for _, rank := range s.registers {
+			sum += 1 / float64(uint64(1)<<rank)
+			if rank == 0 {
+				zeros++
+			}
+		}
+	} else {
+		zeros = int(m) - len(s.sparse)
+		sum = float64(zeros)
+		for _, rank := range s.sparse {
+			sum += 1 / float64(uint64(1)<<rank)
+		}
+	}
+
+	var alpha float64
+	switch s.precision {
+	case 4:
+		alpha = 0.673
+	case 5:
+		alpha = 0.697
+	case 6:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	estimate := alpha * m * m / sum
+	// small cardinalities are estimated more accurately with linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// Merge adds the items in `other` to the Sketch, which must have the same precision
+func (s *Sketch) Merge(other *Sketch) error {
+	if other.precision != s.precision {
+		return fmt.Errorf("%w: %d and %d", ErrSketchMismatch, s.precision, other.precision)
+	}
+
+	if other.registers != nil {
+		if s.registers == nil {
+			s.densify()
+		}
+		for idx, rank := range other.registers {
+			if rank > s.registers[idx] {
+				s.registers[idx] = rank
+			}
+		}
+		return nil
+	}
+
+	for idx, rank := range other.sparse {
+		s.set(idx, rank)
+	}
+	return nil
+}
+
+// Clone returns a copy of the Sketch
+func (s *Sketch) Clone() *Sketch {
+	c := &Sketch{precision: s.precision}
+	if s.registers != nil {
+		c.registers = make([]uint8, len(s.registers))
+		copy(c.registers, s.registers)
+		return c
+	}
+
+	c.sparse = make(map[uint32]uint8, len(s.sparse))
+	for idx, rank := range s.sparse {
+		c.sparse[idx] = rank
+	}
+	return c
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The encoding holds a
+// version, the precision and the representation, followed either by all the registers, or
+// by the number of non-zero registers and each of their (varint) indexes and ranks
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	if s.registers != nil {
+		buf := make([]byte, 3, 3+len(s.registers))
+		buf[0], buf[1], buf[2] = sketchVersion, s.precision, sketchDense
+		return append(buf, s.registers...), nil
+	}
+
+	buf := make([]byte, 3, 3+binary.MaxVarintLen32*(1+len(s.sparse)))
+	buf[0], buf[1], buf[2] = sketchVersion, s.precision, sketchSparse
+	buf = binary.AppendUvarint(buf, uint64(len(s.sparse)))
+	for idx, rank := range s.sparse {
+		buf = binary.AppendUvarint(buf, uint64(idx))
+		buf = append(buf, rank)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != sketchVersion {
+		return ErrInvalidSketch
+	}
+
+	sketch, err := NewSketch(data[1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSketch, err)
+	}
+
+	var (
+		m        = uint64(1) << sketch.precision
+		maxRank  = 64 - sketch.precision + 1
+		payload  = data[3:]
+		validate = func(rank uint8) error {
+			if rank > maxRank {
+				return fmt.Errorf("%w: register rank %d out of bounds", ErrInvalidSketch, rank)
+			}
+			return nil
+		}
+	)
+
+	switch data[2] {
+	case sketchDense:
+		if uint64(len(payload)) != m {
+			return fmt.Errorf("%w: wanted %d registers ; got %d", ErrInvalidSketch, m, len(payload))
+		}
+		for _, rank := range payload {
+			if err := validate(rank); err != nil {
+				return err
+			}
+		}
+		sketch.registers = append([]uint8(nil), payload...)
+		sketch.sparse = nil
+	case sketchSparse:
+		count, n := binary.Uvarint(payload)
+		if n <= 0 || count > m {
+			return fmt.Errorf("%w: invalid register count", ErrInvalidSketch)
+		}
+		payload = payload[n:]
+
+		for i := uint64(0); i < count; i++ {
+			idx, n := binary.Uvarint(payload)
+			if n <= 0 || idx >= m || len(payload) < n+1 {
+				return fmt.Errorf("%w: invalid register", ErrInvalidSketch)
+			}
+			if err := validate(payload[n]); err != nil {
+				return err
+			}
+			sketch.set(uint32(idx), payload[n])
+			payload = payload[n+1:]
+		}
+		if len(payload) > 0 {
+			return fmt.Errorf("%w: trailing data", ErrInvalidSketch)
+		}
+	default:
+		return fmt.Errorf("%w: unknown representation %d", ErrInvalidSketch, data[2])
+	}
+
+	*s = *sketch
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface, as the base64 encoding of MarshalBinary
+func (s *Sketch) MarshalText() ([]byte, error) {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(buf, data)
+	return buf, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (s *Sketch) UnmarshalText(text []byte) error {
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSketch, err)
+	}
+
+	return s.UnmarshalBinary(data[:n])
+}
@@ -0,0 +1,257 @@
+package customerimporter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestPrecisionForError(t *testing.T) {
+	for _, testcase := range []struct {
+		bound float64
+		wants uint8
+		err   error
+	}{
+		{bound: 0.01, wants: 14},
+		{bound: 0.02, wants: 12},
+		{bound: 0.05, wants: 9},
+		{bound: 0.5, wants: MinPrecision},
+		{bound: 0.0001, err: ErrInvalidErrorBound},
+		{bound: 0, err: ErrInvalidErrorBound},
+		{bound: 1, err: ErrInvalidErrorBound},
+		{bound: math.NaN(), err: ErrInvalidErrorBound},
+	} {
+		t.Run(strconv.FormatFloat(testcase.bound, 'g', -1, 64), func(t *testing.T) {
+			precision, err := PrecisionForError(testcase.bound)
+			if !errors.Is(err, testcase.err) {
+				t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+				return
+			}
+			if precision != testcase.wants {
+				t.Errorf("output mismatch error: wanted %d ; got %d", testcase.wants, precision)
+			}
+
+			if err == nil {
+				s, _ := NewSketch(precision)
+				if s.RelativeError() > testcase.bound {
+					t.Errorf("relative error above the bound: %v > %v", s.RelativeError(), testcase.bound)
+				}
+			}
+		})
+	}
+}
+
+func newTestSketch(t *testing.T, precision uint8, from, to int) *Sketch {
+	s, err := NewSketch(precision)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := from; i < to; i++ {
+		s.Add("user" + strconv.Itoa(i) + "@example.com")
+	}
+	return s
+}
+
+func TestSketch(t *testing.T) {
+	if _, err := NewSketch(MaxPrecision + 1); !errors.Is(err, ErrInvalidPrecision) {
+		t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidPrecision, err)
+	}
+
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			s := newTestSketch(t, 14, 0, n)
+
+			// adding the same items again does not change the estimate
+			for i := 0; i < n && i < 100; i++ {
+				s.Add("user" + strconv.Itoa(i) + "@example.com")
+			}
+
+			estimate := float64(s.Estimate())
+			if diff := math.Abs(estimate - float64(n)); diff > 1 && diff/float64(n) > 3*s.RelativeError() {
+				t.Errorf("estimate out of bounds: wanted %d ; got %v", n, estimate)
+			}
+		})
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		a := newTestSketch(t, 12, 0, 30000)
+		b := newTestSketch(t, 12, 20000, 50000)
+		small := newTestSketch(t, 12, 49990, 50010)
+
+		if err := a.Merge(b); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if err := a.Merge(small); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		estimate := float64(a.Estimate())
+		if diff := math.Abs(estimate - 50010); diff/50010 > 3*a.RelativeError() {
+			t.Errorf("estimate out of bounds: wanted %d ; got %v", 50010, estimate)
+		}
+
+		if err := a.Merge(newTestSketch(t, 10, 0, 1)); !errors.Is(err, ErrSketchMismatch) {
+			t.Errorf("unexpected error: wanted %v ; got %v", ErrSketchMismatch, err)
+		}
+	})
+
+	t.Run("MergeIntoSparse", func(t *testing.T) {
+		a := newTestSketch(t, 12, 0, 10)
+		b := newTestSketch(t, 12, 0, 5000)
+		c := b.Clone()
+
+		if err := a.Merge(b); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if a.Estimate() != b.Estimate() || c.Estimate() != b.Estimate() {
+			t.Errorf("output mismatch error: wanted %d ; got %d and %d", b.Estimate(), a.Estimate(), c.Estimate())
+		}
+	})
+}
+
+func TestSketchEncoding(t *testing.T) {
+	for _, n := range []int{0, 50, 20000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			s := newTestSketch(t, 12, 0, n)
+
+			data, err := s.MarshalBinary()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			decoded := new(Sketch)
+			if err = decoded.UnmarshalBinary(data); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if decoded.Precision() != s.Precision() || decoded.Estimate() != s.Estimate() {
+				t.Errorf("output mismatch error: wanted %d ; got %d", s.Estimate(), decoded.Estimate())
+			}
+
+			// the decoded sketch keeps counting
+			decoded.Add("another@example.com")
+			if decoded.Estimate() < s.Estimate() {
+				t.Errorf("expected the estimate not to decrease: %d < %d", decoded.Estimate(), s.Estimate())
+			}
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		sketches := map[string]*Sketch{
+			"a.com": newTestSketch(t, 10, 0, 20),
+			"b.com": newTestSketch(t, 10, 0, 2000),
+		}
+
+		data, err := json.Marshal(sketches)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		decoded := map[string]*Sketch{}
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		for domain, s := range sketches {
+			if decoded[domain] == nil || decoded[domain].Estimate() != s.Estimate() {
+				t.Errorf("output mismatch error for %q: wanted %d ; got %v", domain, s.Estimate(), decoded[domain])
+			}
+		}
+	})
+
+	for _, testcase := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty"},
+		{name: "Version", data: []byte{9, 12, 0}},
+		{name: "Precision", data: []byte{1, 30, 0}},
+		{name: "Representation", data: []byte{1, 4, 7}},
+		{name: "DenseLength", data: []byte{1, 4, 0, 1, 2}},
+		{name: "DenseRank", data: append([]byte{1, 4, 0, 99}, make([]byte, 15)...)},
+		{name: "SparseIndex", data: []byte{1, 4, 1, 1, 16, 1}},
+		{name: "SparseTruncated", data: []byte{1, 4, 1, 2, 3, 1}},
+		{name: "SparseTrailing", data: []byte{1, 4, 1, 1, 3, 1, 0}},
+	} {
+		t.Run("Fail"+testcase.name, func(t *testing.T) {
+			if err := new(Sketch).UnmarshalBinary(testcase.data); !errors.Is(err, ErrInvalidSketch) {
+				t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidSketch, err)
+			}
+		})
+	}
+}
+
+func TestApproximateDedup(t *testing.T) {
+	t.Run("Small", func(t *testing.T) {
+		const input = `email
+a@gmail.com
+a@gmail.com
+b@gmail.com
+c@gmail.com
+jane@company.com
+jane@company.com
+`
+
+		res, err := CountReader(strings.NewReader(input), WithApproximateDedup(0.01))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if e, ok := res.Lookup("gmail.com"); !ok || e.Count != 4 || e.Unique != 3 {
+			t.Errorf("output mismatch error: wanted 4 rows and 3 unique ; got %v", e)
+		}
+		if e, ok := res.Lookup("company.com"); !ok || e.Count != 2 || e.Unique != 1 {
+			t.Errorf("output mismatch error: wanted 2 rows and 1 unique ; got %v", e)
+		}
+		if res.Duplicates != 2 || res.Sketch.Estimate() != 4 {
+			t.Errorf("output mismatch error: wanted 2 duplicates and 4 unique ; got %d and %d", res.Duplicates, res.Sketch.Estimate())
+		}
+		if res.Sketch.Precision() != 14 || len(res.DomainSketches) != 2 {
+			t.Errorf("expected a global and 2 domain sketches with precision 14; got %d and %d", res.Sketch.Precision(), len(res.DomainSketches))
+		}
+	})
+
+	t.Run("Customers", func(t *testing.T) {
+		res, err := Count(rawPath, WithApproximateDedup(0.02))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		exact, err := Count(rawPath, WithDedup())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		estimate := float64(res.Sketch.Estimate())
+		if diff := math.Abs(estimate - float64(exact.Valid-exact.Duplicates)); diff/float64(exact.Valid) > 3*0.02 {
+			t.Errorf("estimate out of bounds: wanted %d ; got %v", exact.Valid-exact.Duplicates, estimate)
+		}
+
+		for _, e := range exact.Entries {
+			approx, ok := res.Lookup(e.Domain)
+			if !ok || approx.Count != e.Count {
+				t.Errorf("output mismatch error for %q: wanted %v ; got %v", e.Domain, e, approx)
+				continue
+			}
+
+			// small sets are estimated almost exactly, but may still hit the same register
+			if diff := math.Abs(float64(approx.Unique - e.Unique)); diff > 1 && diff/float64(e.Unique) > 3*0.02 {
+				t.Errorf("estimate out of bounds for %q: wanted %d ; got %d", e.Domain, e.Unique, approx.Unique)
+			}
+		}
+	})
+}
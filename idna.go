@@ -0,0 +1,346 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters (RFC 3492, section 5)
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+
+	acePrefix = "xn--"
+)
+
+var (
+	ErrInvalidIDNAForm = errors.New("invalid IDNA form")
+	ErrPunycode        = fmt.Errorf("%w: invalid punycode label", ErrInvalidDomain)
+)
+
+// IDNAForm defines how internationalized domain names are handled
+type IDNAForm uint8
+
+const (
+	// IDNANone counts domains as they are, so Unicode and punycode forms of the same
+	// domain are counted separately. This is the default
+	IDNANone IDNAForm = iota
+	// IDNAASCII counts Unicode and punycode forms under the same (ASCII) domain, showing
+	// it in its punycode form (`xn--bcher-kva.de`)
+	IDNAASCII
+	// IDNAUnicode counts Unicode and punycode forms under the same domain, showing it in
+	// its Unicode form (`bücher.de`)
+	IDNAUnicode
+)
+
+var idnaFormNames = map[IDNAForm]string{
+	IDNANone:    "none",
+	IDNAASCII:   "ascii",
+	IDNAUnicode: "unicode",
+}
+
+// String implements the fmt.Stringer interface
+func (f IDNAForm) String() string {
+	if name, ok := idnaFormNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("IDNAForm(%d)", f)
+}
+
+// ParseIDNAForm returns the IDNAForm for `name`, one of `none`, `ascii` (or `punycode`) and `unicode`
+func ParseIDNAForm(name string) (IDNAForm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for f, n := range idnaFormNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	if name == "punycode" {
+		return IDNAASCII, nil
+	}
+
+	return IDNANone, fmt.Errorf("%w: %q", ErrInvalidIDNAForm, name)
+}
+
+// ToASCII converts the domain name `domain` to its canonical ASCII form: labels with
+// non-ASCII characters are lowercased and encoded with punycode (RFC 3492) under the `xn--`
+// prefix, while ASCII labels are lowercased. Existing `xn--` labels are checked to be valid
+// punycode. Input is expected in Unicode Normalization Form C, as produced by most systems
+func ToASCII(domain string) (string, error) {
+	if isASCII(domain) && !containsFold(domain, acePrefix) {
+		return strings.ToLower(domain), nil
+	}
+
+	labels := strings.Split(domain, ".")
+	for idx, label := range labels {
+		label = strings.ToLower(label)
+
+		switch {
+		case isASCII(label):
+			if strings.HasPrefix(label, acePrefix) {
+				if _, err := punyDecode(label[len(acePrefix):]); err != nil {
+					return "", err
+				}
+			}
+		case strings.HasPrefix(label, acePrefix):
+			// a label with the ACE prefix must be valid punycode, so it cannot hold Unicode characters
+			return "", ErrPunycode
+		default:
+			encoded, err := punyEncode(label)
+			if err != nil {
+				return "", err
+			}
+			label = acePrefix + encoded
+		}
+
+		labels[idx] = label
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the domain name `domain` to its Unicode form, decoding the labels
+// with an `xn--` prefix from punycode (RFC 3492). Other labels are lowercased
+func ToUnicode(domain string) (string, error) {
+	if !containsFold(domain, acePrefix) {
+		return strings.ToLower(domain), nil
+	}
+
+	labels := strings.Split(domain, ".")
+	for idx, label := range labels {
+		label = strings.ToLower(label)
+
+		if strings.HasPrefix(label, acePrefix) {
+			decoded, err := punyDecode(label[len(acePrefix):])
+			if err != nil {
+				return "", err
+			}
+			label = decoded
+		}
+
+		labels[idx] = label
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether the ASCII `substr` is within `s`, case-insensitively
+func containsFold(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// punyAdapt is the bias adaptation function (RFC 3492, section 6.1)
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// punyThreshold returns the threshold `t` for the digit position `k`
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	default:
+		return k - bias
+	}
+}
+
+func punyEncodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyDecodeDigit(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	default:
+		return 0, false
+	}
+}
+
+// punyEncode encodes the Unicode `label` with punycode (RFC 3492, section 6.3), without the ACE prefix
+func punyEncode(label string) (string, error) {
+	var (
+		input  = []rune(label)
+		output = make([]byte, 0, len(label)+8)
+	)
+
+	for _, r := range input {
+		if r < utf8.RuneSelf {
+			output = append(output, byte(r))
+		}
+	}
+
+	var (
+		n     = punyInitialN
+		delta = 0
+		bias  = punyInitialBias
+		b     = len(output)
+		h     = b
+	)
+	if b > 0 {
+		output = append(output, '-')
+	}
+
+	for h < len(input) {
+		m := math.MaxInt32
+		for _, r := range input {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+
+		if (m - n) > (math.MaxInt32-delta)/(h+1) {
+			return "", ErrPunycode
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+				if delta == math.MaxInt32 {
+					return "", ErrPunycode
+				}
+			}
+			if int(r) != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				output = append(output, punyEncodeDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			output = append(output, punyEncodeDigit(q))
+
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(output), nil
+}
+
+// punyDecode decodes the punycode `encoded` label (RFC 3492, section 6.2), without the ACE prefix
+func punyDecode(encoded string) (string, error) {
+	var (
+		output []rune
+		pos    int
+	)
+
+	if b := strings.LastIndexByte(encoded, '-'); b >= 0 {
+		for i := 0; i < b; i++ {
+			if encoded[i] >= utf8.RuneSelf {
+				return "", ErrPunycode
+			}
+			output = append(output, rune(encoded[i]))
+		}
+		pos = b + 1
+	}
+
+	var (
+		n    = punyInitialN
+		i    = 0
+		bias = punyInitialBias
+	)
+
+	for pos < len(encoded) {
+		oldi, w := i, 1
+
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(encoded) {
+				return "", ErrPunycode
+			}
+
+			digit, ok := punyDecodeDigit(encoded[pos])
+			pos++
+			if !ok || digit > (math.MaxInt32-i)/w {
+				return "", ErrPunycode
+			}
+
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+
+			if w > math.MaxInt32/(punyBase-t) {
+				return "", ErrPunycode
+			}
+			w *= punyBase - t
+		}
+
+		numPoints := len(output) + 1
+		bias = punyAdapt(i-oldi, numPoints, oldi == 0)
+
+		if i/numPoints > math.MaxInt32-n {
+			return "", ErrPunycode
+		}
+		n += i / numPoints
+		i %= numPoints
+
+		if n < punyInitialN || n > utf8.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+			return "", ErrPunycode
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,129 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestIDNA(t *testing.T) {
+	for _, testcase := range []struct {
+		unicode string
+		ascii   string
+	}{
+		{unicode: "bücher.de", ascii: "xn--bcher-kva.de"},
+		{unicode: "münchen.de", ascii: "xn--mnchen-3ya.de"},
+		{unicode: "пример.рф", ascii: "xn--e1afmkfd.xn--p1ai"},
+		{unicode: "example.com", ascii: "example.com"},
+		// sample strings from RFC 3492, section 7.1
+		{unicode: "ليهمابتكلموشعربي؟", ascii: "xn--egbpdaj6bu4bxfgehfvwxn"},
+		{unicode: "他们为什么不说中文", ascii: "xn--ihqwcrb4cv8a8dqg056pqjye"},
+		{unicode: "почемужеонинеговорятпорусски", ascii: "xn--b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{unicode: "pročprostěnemluvíčesky", ascii: "xn--proprostnemluvesky-uyb24dma41a"},
+	} {
+		t.Run(testcase.ascii, func(t *testing.T) {
+			ascii, err := ToASCII(testcase.unicode)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if ascii != testcase.ascii {
+				t.Errorf("ToASCII mismatch: wanted %q ; got %q", testcase.ascii, ascii)
+			}
+
+			unicode, err := ToUnicode(testcase.ascii)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if unicode != testcase.unicode {
+				t.Errorf("ToUnicode mismatch: wanted %q ; got %q", testcase.unicode, unicode)
+			}
+		})
+	}
+
+	t.Run("CaseFolding", func(t *testing.T) {
+		ascii, err := ToASCII("BÜCHER.De")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if ascii != "xn--bcher-kva.de" {
+			t.Errorf("ToASCII mismatch: wanted %q ; got %q", "xn--bcher-kva.de", ascii)
+		}
+	})
+
+	t.Run("InvalidPunycode", func(t *testing.T) {
+		for _, input := range []string{"xn--a_b.com", "xn--bcher-kv.de", "XN--ñ.com"} {
+			if _, err := ToUnicode(input); !errors.Is(err, ErrPunycode) {
+				t.Errorf("unexpected error for %q: wanted %v ; got %v", input, ErrPunycode, err)
+			}
+			if _, err := ToASCII(input); !errors.Is(err, ErrInvalidDomain) {
+				t.Errorf("unexpected error for %q: wanted %v ; got %v", input, ErrInvalidDomain, err)
+			}
+		}
+	})
+}
+
+func TestIDNACount(t *testing.T) {
+	const input = `email
+user@bücher.de
+user@xn--bcher-kva.de
+other@BÜCHER.de
+a@пример.рф
+b@xn--e1afmkfd.XN--P1AI
+c@example.com
+`
+
+	for _, testcase := range []struct {
+		form  IDNAForm
+		wants map[string]int
+	}{
+		{
+			form: IDNANone,
+			wants: map[string]int{
+				"bücher.de":             2,
+				"xn--bcher-kva.de":      1,
+				"пример.рф":             1,
+				"xn--e1afmkfd.xn--p1ai": 1,
+				"example.com":           1,
+			},
+		},
+		{
+			form: IDNAASCII,
+			wants: map[string]int{
+				"xn--bcher-kva.de":      3,
+				"xn--e1afmkfd.xn--p1ai": 2,
+				"example.com":           1,
+			},
+		},
+		{
+			form: IDNAUnicode,
+			wants: map[string]int{
+				"bücher.de":   3,
+				"пример.рф":   2,
+				"example.com": 1,
+			},
+		},
+	} {
+		t.Run(testcase.form.String(), func(t *testing.T) {
+			// Unicode labels are validated in their ASCII form, with or without the IDNA conversion
+			entries, err := ParseReader(strings.NewReader(input), WithIDNA(testcase.form), WithStrictValidation())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if len(entries) != len(testcase.wants) {
+				t.Errorf("output length mismatch error: wanted %d ; got %d: %v", len(testcase.wants), len(entries), entries)
+			}
+			for _, e := range entries {
+				if testcase.wants[e.Domain] != e.Count {
+					t.Errorf("output mismatch error: expected domain %s to have %d users ; has %d", e.Domain, testcase.wants[e.Domain], e.Count)
+				}
+			}
+		})
+	}
+}
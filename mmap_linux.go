@@ -0,0 +1,45 @@
+//go:build linux
+
+package customerimporter
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errNotRegular = errors.New("not a regular file")
+
+// mmapFile maps the contents of the file `f` into memory, read-only, returning the mapped data
+// and a function to unmap it. The pages are only read from the file as they are accessed, and
+// may be dropped again once read, so the file may be larger than the available memory
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, errNotRegular
+	}
+
+	size := info.Size()
+	if size == 0 {
+		// empty files cannot be mapped
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		// the file is larger than the address space
+		return nil, nil, syscall.EFBIG
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the data is read once, from start to end: the kernel reads ahead aggressively, and frees
+	// the pages already read first when short on memory
+	_ = syscall.Madvise(data, syscall.MADV_SEQUENTIAL)
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
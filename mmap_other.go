@@ -0,0 +1,15 @@
+//go:build !linux
+
+package customerimporter
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("memory-mapped input is not supported on this platform")
+
+// mmapFile is not supported outside of Linux, so files are always read through a buffer
+func mmapFile(*os.File) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,107 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestMemoryMap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return path
+	}
+
+	for _, testcase := range []struct {
+		name string
+		path string
+		opts []Option
+	}{
+		{
+			name: "Customers",
+			path: "./testdata/customers.csv",
+		},
+		{
+			name: "Mixed",
+			path: write("mixed.csv", parallelInput(t)),
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCollect), WithDedup()},
+		},
+		{
+			name: "MixedWorkers",
+			path: write("mixed-workers.csv", parallelInput(t)),
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCollect), WithDedup(), WithWorkers(3)},
+		},
+		{
+			// the line endings are normalized without writing to the read-only mapping
+			name: "CRLF",
+			path: write("crlf.csv", []byte("name,email\r\njohn,\"john\r\n\"@example.com\r\njane,jane@example.com\r")),
+			opts: []Option{WithErrorPolicy(SkipAndCollect)},
+		},
+		{
+			name: "DetectDelimiter",
+			path: write("semicolon.csv", []byte("name;email\njohn;john@example.com\njane;jane@example.com")),
+			opts: []Option{WithDelimiterDetection()},
+		},
+		{
+			name: "FailFast",
+			path: write("invalid.csv", []byte("name,email\njohn,john@example.com\njane,not-an-address\n")),
+		},
+		{
+			name: "Empty",
+			path: write("empty.csv", nil),
+		},
+		{
+			// not a regular file, so it is read through a buffer
+			name: "Fallback",
+			path: os.DevNull,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			wanted, wantedErr := Count(testcase.path, testcase.opts...)
+			res, err := Count(testcase.path, append(testcase.opts, WithMemoryMap())...)
+
+			if wantedErr != nil || err != nil {
+				if !errors.Is(err, wantedErr) && (err == nil || wantedErr == nil || err.Error() != wantedErr.Error()) {
+					t.Errorf("unexpected error: wanted %v ; got %v", wantedErr, err)
+				}
+				return
+			}
+
+			res.Elapsed, wanted.Elapsed = 0, 0
+			if !reflect.DeepEqual(wanted, res) {
+				t.Errorf("output mismatch error: wanted %d rows, %d invalid and %d entries ; got %d rows, %d invalid and %d entries",
+					wanted.Rows, wanted.Invalid, len(wanted.Entries), res.Rows, res.Invalid, len(res.Entries))
+			}
+		})
+	}
+
+	t.Run("Parse", func(t *testing.T) {
+		wanted, err := Parse("./testdata/customers.csv")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		entries, err := Parse("./testdata/customers.csv", WithMemoryMap())
+		if err != nil {
+			t.Errorf("unexpected error: wanted nil ; got %v", err)
+			return
+		}
+		if !reflect.DeepEqual(wanted, entries) {
+			t.Errorf("output mismatch error: wanted %d entries ; got %d entries", len(wanted), len(entries))
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := Parse(filepath.Join(dir, "missing.csv"), WithMemoryMap()); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("unexpected error: wanted %v ; got %v", os.ErrNotExist, err)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidNormalization = errors.New("invalid normalization rule")
+
+// Normalization is a set of rules applied to an email address before extracting its domain.
+// Rules are combined with a bitwise OR, such as `NormTrimSpace | NormLowerDomain`
+type Normalization uint16
+
+const (
+	// NormTrimSpace removes the leading and trailing whitespace from the address
+	NormTrimSpace Normalization = 1 << iota
+	// NormAngleAddr extracts the address from name-addr forms such as `Jane <jane@x.org>`
+	NormAngleAddr
+	// NormLastAt splits the address on its last `@` outside of a quoted local-part, as in
+	// RFC 5322 (`"weird@local"@example.com`), instead of on the first `@`
+	NormLastAt
+	// NormTrailingDot strips the trailing dots from the domain (`example.com.`)
+	NormTrailingDot
+	// NormLowerDomain converts the domain to lowercase
+	NormLowerDomain
+
+	// NormNone disables normalization; the domain is everything after the first `@`
+	NormNone Normalization = 0
+	// NormAll enables every normalization rule. This is the default
+	NormAll = NormTrimSpace | NormAngleAddr | NormLastAt | NormTrailingDot | NormLowerDomain
+)
+
+var normalizationNames = []struct {
+	rule Normalization
+	name string
+}{
+	{NormTrimSpace, "trim"},
+	{NormAngleAddr, "angle"},
+	{NormLastAt, "last-at"},
+	{NormTrailingDot, "trailing-dot"},
+	{NormLowerDomain, "lower"},
+}
+
+// String implements the fmt.Stringer interface, listing the enabled rules separated by commas
+func (n Normalization) String() string {
+	switch n {
+	case NormNone:
+		return "none"
+	case NormAll:
+		return "all"
+	}
+
+	names := make([]string, 0, len(normalizationNames))
+	for _, rule := range normalizationNames {
+		if n&rule.rule != 0 {
+			names = append(names, rule.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseNormalization returns the Normalization for a comma-separated list of rule names:
+// `trim`, `angle`, `last-at`, `trailing-dot` and `lower`; or `all` and `none`
+func ParseNormalization(names string) (Normalization, error) {
+	var n Normalization
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			n |= NormAll
+			continue
+		}
+
+		var found bool
+		for _, rule := range normalizationNames {
+			if rule.name == name {
+				n |= rule.rule
+				found = true
+				break
+			}
+		}
+		if !found {
+			return NormNone, fmt.Errorf("%w: %q", ErrInvalidNormalization, name)
+		}
+	}
+
+	return n, nil
+}
+
+// SplitAddress normalizes the email address `email` according to the rules in `n`, and
+// splits it into its local-part and domain. Returns ErrInvalidDomain if the address has
+// no `@` separator
+func SplitAddress(email string, n Normalization) (local, domain string, err error) {
+	if n&NormTrimSpace != 0 {
+		email = strings.TrimSpace(email)
+	}
+
+	if n&NormAngleAddr != 0 {
+		email = angleAddr(email)
+		if n&NormTrimSpace != 0 {
+			email = strings.TrimSpace(email)
+		}
+	}
+
+	idx := -1
+	if n&NormLastAt != 0 {
+		idx = lastUnquotedAt(email)
+	} else {
+		idx = strings.IndexByte(email, '@')
+	}
+	if idx < 0 {
+		return "", "", ErrInvalidDomain
+	}
+
+	local, domain = email[:idx], email[idx+1:]
+
+	if n&NormTrailingDot != 0 {
+		domain = strings.TrimRight(domain, ".")
+	}
+	if n&NormLowerDomain != 0 {
+		// strings.ToLower returns the input string as-is when it has no uppercase characters
+		domain = strings.ToLower(domain)
+	}
+
+	return local, domain, nil
+}
+
+// angleAddr returns the address enclosed in the last unquoted pair of angle brackets in
+// `email` (as in `Jane <jane@x.org>`), or `email` itself if it is not in that form
+func angleAddr(email string) string {
+	if !strings.HasSuffix(email, ">") {
+		return email
+	}
+
+	var (
+		quoted bool
+		start  = -1
+	)
+	for i := 0; i < len(email)-1; i++ {
+		switch email[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '<':
+			if !quoted {
+				start = i
+			}
+		}
+	}
+
+	if start < 0 {
+		return email
+	}
+	return email[start+1 : len(email)-1]
+}
+
+// lastUnquotedAt returns the index of the last `@` in `email` that is not part of a quoted
+// string (including escaped characters within it), or -1 if there is none
+func lastUnquotedAt(email string) int {
+	var (
+		quoted bool
+		idx    = -1
+	)
+
+	for i := 0; i < len(email); i++ {
+		switch email[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '@':
+			if !quoted {
+				idx = i
+			}
+		}
+	}
+
+	return idx
+}
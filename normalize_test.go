@@ -0,0 +1,173 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestSplitAddress(t *testing.T) {
+	for _, testcase := range []struct {
+		name   string
+		input  string
+		rules  Normalization
+		local  string
+		domain string
+		err    error
+	}{
+		{
+			name:   "None",
+			input:  " John@Gmail.COM. ",
+			rules:  NormNone,
+			local:  " John",
+			domain: "Gmail.COM. ",
+		},
+		{
+			name:   "TrimSpace",
+			input:  " John@Gmail.COM ",
+			rules:  NormTrimSpace,
+			local:  "John",
+			domain: "Gmail.COM",
+		},
+		{
+			name:   "LowerDomain",
+			input:  "John@Gmail.COM",
+			rules:  NormLowerDomain,
+			local:  "John",
+			domain: "gmail.com",
+		},
+		{
+			name:   "TrailingDot",
+			input:  "john@gmail.com..",
+			rules:  NormTrailingDot,
+			local:  "john",
+			domain: "gmail.com",
+		},
+		{
+			name:   "FirstAt",
+			input:  `"weird@local"@example.com`,
+			rules:  NormNone,
+			local:  `"weird`,
+			domain: `local"@example.com`,
+		},
+		{
+			name:   "LastAt",
+			input:  `"weird@local"@example.com`,
+			rules:  NormLastAt,
+			local:  `"weird@local"`,
+			domain: "example.com",
+		},
+		{
+			name:   "LastAtEscapedQuote",
+			input:  `"we\"ird@local"@example.com`,
+			rules:  NormLastAt,
+			local:  `"we\"ird@local"`,
+			domain: "example.com",
+		},
+		{
+			name:   "AngleAddr",
+			input:  "Jane <jane@x.org>",
+			rules:  NormAngleAddr,
+			local:  "jane",
+			domain: "x.org",
+		},
+		{
+			name:   "AngleAddrQuotedName",
+			input:  `"Doe <Jane>" <jane@x.org>`,
+			rules:  NormAngleAddr,
+			local:  "jane",
+			domain: "x.org",
+		},
+		{
+			name:   "All",
+			input:  ` "J. Doe" < "j@d"@Example.ORG. > `,
+			rules:  NormAll,
+			local:  `"j@d"`,
+			domain: "example.org",
+		},
+		{
+			name:  "QuotedAtOnly",
+			input: `"john@example.com"`,
+			rules: NormAll,
+			err:   ErrInvalidDomain,
+		},
+		{
+			name:  "NoAt",
+			input: "john.example.com",
+			rules: NormAll,
+			err:   ErrInvalidDomain,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			local, domain, err := SplitAddress(testcase.input, testcase.rules)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if local != testcase.local || domain != testcase.domain {
+				t.Errorf("output mismatch error: wanted %q @ %q ; got %q @ %q", testcase.local, testcase.domain, local, domain)
+			}
+		})
+	}
+}
+
+func TestParseNormalization(t *testing.T) {
+	for input, wants := range map[string]Normalization{
+		"all":               NormAll,
+		"none":              NormNone,
+		"":                  NormNone,
+		"trim, lower":       NormTrimSpace | NormLowerDomain,
+		"last-at,angle":     NormLastAt | NormAngleAddr,
+		"trailing-dot,none": NormTrailingDot,
+	} {
+		n, err := ParseNormalization(input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", input, err)
+			continue
+		}
+		if n != wants {
+			t.Errorf("normalization mismatch for %q: wanted %v ; got %v", input, wants, n)
+		}
+		if parsed, _ := ParseNormalization(n.String()); parsed != n {
+			t.Errorf("round-trip mismatch for %v: got %v", n, parsed)
+		}
+	}
+
+	if _, err := ParseNormalization("trim,upper"); !errors.Is(err, ErrInvalidNormalization) {
+		t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidNormalization, err)
+	}
+}
+
+func TestNormalizedCount(t *testing.T) {
+	const input = `email
+John@Gmail.COM 
+john@gmail.com.
+Jane <jane@gmail.com>
+"""weird@local""@example.com"
+`
+
+	res, err := CountReader(strings.NewReader(input))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if e, ok := res.Lookup("gmail.com"); !ok || e.Count != 3 {
+		t.Errorf("output mismatch error: wanted {3 gmail.com} ; got %v", e)
+	}
+	if e, ok := res.Lookup("example.com"); !ok || e.Count != 1 {
+		t.Errorf("output mismatch error: wanted {1 example.com} ; got %v", e)
+	}
+	if res.Distinct() != 2 {
+		t.Errorf("distinct domains mismatch: wanted %d ; got %d", 2, res.Distinct())
+	}
+}
@@ -0,0 +1,393 @@
+package customerimporter
+
+import "runtime"
+
+// DefaultColumnNames lists the header names (matched case-insensitively) that are recognized
+// as the email column when none are configured with WithColumnNames
+var DefaultColumnNames = []string{
+	"email",
+	"e-mail",
+	"email_address",
+	"email address",
+	"e-mail address",
+	"emailaddress",
+}
+
+// Option configures how the CSV data is parsed
+type Option func(*config)
+
+type config struct {
+	columnNames []string
+	columnIdx   int
+	noHeader    bool
+
+	comma            rune
+	comment          rune
+	lazyQuotes       bool
+	trimLeadingSpace bool
+	fieldsPerRecord  int
+	sniff            bool
+
+	errorPolicy ErrorPolicy
+	maxErrors   int
+
+	sortOrder     SortOrder
+	normalization Normalization
+	strict        bool
+	idna          IDNAForm
+	rollup        *SuffixList
+	disposable    *DomainList
+	classifier    *Classifier
+	aliases       *AliasMap
+	typos         *TypoDetector
+	mergeTypos    bool
+	dns           *DNSValidator
+
+	dedup           bool
+	sketchPrecision uint8
+
+	topK         int
+	topKCapacity int
+
+	workers int
+	mmap    bool
+}
+
+func newConfig(opts ...Option) config {
+	cfg := config{
+		columnNames:   DefaultColumnNames,
+		columnIdx:     -1,
+		comma:         ',',
+		maxErrors:     defaultMaxErrors,
+		normalization: NormAll,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	return cfg
+}
+
+// WithColumnNames sets the header names that identify the email column, replacing
+// DefaultColumnNames. Names are matched case-insensitively and ignoring surrounding
+// whitespace. Empty names are ignored; if none are left the option is a no-op
+func WithColumnNames(names ...string) Option {
+	return func(c *config) {
+		valid := make([]string, 0, len(names))
+		for _, n := range names {
+			if n != "" {
+				valid = append(valid, n)
+			}
+		}
+		if len(valid) == 0 {
+			return
+		}
+
+		c.columnNames = valid
+	}
+}
+
+// WithColumnIndex reads the email address from the column at (zero-based) index `idx`,
+// for CSV data without a header row. Every record, including the first one, is
+// treated as data. Negative indexes are ignored
+func WithColumnIndex(idx int) Option {
+	return func(c *config) {
+		if idx < 0 {
+			return
+		}
+
+		c.columnIdx = idx
+		c.noHeader = true
+	}
+}
+
+// WithComma sets the field delimiter, such as ';' for European exports or '\t' for TSV files.
+// It must be a valid rune that is not '\r', '\n', '"' nor the Unicode replacement character,
+// otherwise parsing fails with an error from the csv package. Defaults to ','
+func WithComma(comma rune) Option {
+	return func(c *config) {
+		c.comma = comma
+		c.sniff = false
+	}
+}
+
+// WithComment sets the comment character; lines beginning with it (without leading
+// whitespace) are ignored. Disabled by default
+func WithComment(comment rune) Option {
+	return func(c *config) {
+		c.comment = comment
+	}
+}
+
+// WithLazyQuotes allows quotes to appear in unquoted fields, and non-doubled quotes
+// to appear in quoted fields
+func WithLazyQuotes() Option {
+	return func(c *config) {
+		c.lazyQuotes = true
+	}
+}
+
+// WithTrimLeadingSpace ignores the leading white space in each field
+func WithTrimLeadingSpace() Option {
+	return func(c *config) {
+		c.trimLeadingSpace = true
+	}
+}
+
+// WithFieldsPerRecord sets the expected number of fields per record, following the
+// semantics of csv.Reader: a positive value requires every record to have exactly
+// that many fields, zero (the default) requires every record to match the first one,
+// and a negative value allows a variable number of fields
+func WithFieldsPerRecord(n int) Option {
+	return func(c *config) {
+		c.fieldsPerRecord = n
+	}
+}
+
+// WithDelimiterDetection inspects the first lines of the input and picks the delimiter
+// among ',', ';', '\t' and '|' that splits every line into the same number of fields.
+// If none qualifies, the configured comma is used. It is overridden by a subsequent
+// WithComma option
+func WithDelimiterDetection() Option {
+	return func(c *config) {
+		c.sniff = true
+	}
+}
+
+// WithErrorPolicy sets how invalid rows are handled: abort on the first one (FailFast, the
+// default), or skip them while counting (SkipAndCount) or collecting (SkipAndCollect) them
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(c *config) {
+		c.errorPolicy = policy
+	}
+}
+
+// WithMaxErrors sets the maximum number of RowError collected with the SkipAndCollect policy.
+// Defaults to 100; non-positive values are ignored
+func WithMaxErrors(n int) Option {
+	return func(c *config) {
+		if n <= 0 {
+			return
+		}
+
+		c.maxErrors = n
+	}
+}
+
+// WithSort sets the order of the returned entries. Defaults to SortByDomain
+func WithSort(order SortOrder) Option {
+	return func(c *config) {
+		c.sortOrder = order
+	}
+}
+
+// WithNormalization sets the rules applied to each email address before extracting its
+// domain. Defaults to NormAll; NormNone takes everything after the first `@` verbatim
+func WithNormalization(n Normalization) Option {
+	return func(c *config) {
+		c.normalization = n
+	}
+}
+
+// WithStrictValidation checks the syntax of each address (see ValidateAddress) after it is
+// normalized, rejecting the invalid ones with an error describing the failure, such as
+// ErrLocalPartEmpty or ErrDomainLabelEmpty. Without it, any address with an `@` is counted
+func WithStrictValidation() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithIDNA counts the Unicode (`bücher.de`) and punycode (`xn--bcher-kva.de`) forms of
+// internationalized domain names under the same domain, shown in the form set by `form`.
+// Defaults to IDNANone, counting each form separately
+func WithIDNA(form IDNAForm) Option {
+	return func(c *config) {
+		c.idna = form
+	}
+}
+
+// WithRollup groups the counts by registrable domain (eTLD+1, such as `company.com` for
+// `mail.company.com` or `bbc.co.uk` for `news.bbc.co.uk`), according to the SuffixList `list`.
+// The counts for the original domains are kept in each Entry's Children. A nil `list`
+// uses DefaultSuffixList
+func WithRollup(list *SuffixList) Option {
+	return func(c *config) {
+		if list == nil {
+			list = DefaultSuffixList()
+		}
+
+		c.rollup = list
+	}
+}
+
+// WithDisposableDomains flags the entries for disposable email providers (see Entry.Disposable
+// and Result.Disposable), according to the DomainList `list`. A nil `list` uses
+// DefaultDisposableDomains, which can also be extended and passed in
+func WithDisposableDomains(list *DomainList) Option {
+	return func(c *config) {
+		if list == nil {
+			list = DefaultDisposableDomains()
+		}
+
+		c.disposable = list
+	}
+}
+
+// WithClassifier sets the Category of each entry (see Entry.Category and Result.Categories),
+// according to the Classifier `cl`. A nil `cl` uses DefaultClassifier, which can also be
+// extended and passed in
+func WithClassifier(cl *Classifier) Option {
+	return func(c *config) {
+		if cl == nil {
+			cl = DefaultClassifier()
+		}
+
+		c.classifier = cl
+	}
+}
+
+// WithAliases folds the domains in the AliasMap `m` into their canonical domain before
+// counting them. The counts for each alias are kept in Entry.Aliases
+func WithAliases(m *AliasMap) Option {
+	return func(c *config) {
+		c.aliases = m
+	}
+}
+
+// WithTypoDetection reports the domains that are likely misspellings of another one in
+// Result.Typos, according to the TypoDetector `d`. A nil `d` uses NewTypoDetector(nil)
+func WithTypoDetection(d *TypoDetector) Option {
+	return func(c *config) {
+		if d == nil {
+			d = NewTypoDetector(nil)
+		}
+
+		c.typos = d
+		c.mergeTypos = false
+	}
+}
+
+// WithTypoCorrection is like WithTypoDetection, but also merges the count of each typo into
+// its suggested correction. The counts for each merged typo are kept in Entry.Aliases
+func WithTypoCorrection(d *TypoDetector) Option {
+	return func(c *config) {
+		WithTypoDetection(d)(c)
+		c.mergeTypos = true
+	}
+}
+
+// WithDNSValidation checks whether each counted domain can receive email (see
+// Entry.Deliverability), with the DNSValidator `v`. A nil `v` uses NewDNSValidator(nil, 0, 0)
+func WithDNSValidation(v *DNSValidator) Option {
+	return func(c *config) {
+		if v == nil {
+			v = NewDNSValidator(nil, 0, 0)
+		}
+
+		c.dns = v
+	}
+}
+
+// WithDedup counts the distinct (normalized) addresses for each domain in Entry.Unique,
+// alongside the number of rows in Entry.Count, and lists the addresses found in more than
+// one row in Result.DuplicateAddresses. The addresses are tracked in an exact set of 64-bit
+// hashes, so memory usage grows with the number of distinct addresses
+func WithDedup() Option {
+	return func(c *config) {
+		c.dedup = true
+		c.sketchPrecision = 0
+	}
+}
+
+// WithApproximateDedup estimates the distinct addresses for each domain in Entry.Unique, and
+// overall, with HyperLogLog sketches (see Result.Sketch and Result.DomainSketches) instead
+// of an exact set, using a fixed amount of memory for each domain. The relative standard
+// error of the estimates is up to `bound` (such as 0.01 for 1%); bounds outside of (0, 1)
+// use 1%, and bounds too small for MaxPrecision use MaxPrecision.
+//
+// Result.Duplicates is estimated as well, and duplicated addresses are not listed
+func WithApproximateDedup(bound float64) Option {
+	return func(c *config) {
+		if !(bound > 0 && bound < 1) {
+			bound = defaultErrorBound
+		}
+
+		precision, err := PrecisionForError(bound)
+		if err != nil {
+			precision = MaxPrecision
+		}
+
+		c.dedup = false
+		c.sketchPrecision = precision
+	}
+}
+
+// DefaultTopKCapacity is the minimum number of counters used by WithTopK when no capacity is set
+const DefaultTopKCapacity = 1000
+
+// WithTopK only keeps the `k` most frequent domains, counted within a fixed memory budget of
+// `capacity` counters (see HeavyHitters), instead of counting every distinct domain. Counts
+// become upper bounds, with the maximum overestimation in Entry.Error and the maximum count of
+// any domain left out in Result.ErrorBound (at least the count of the next tracked domain).
+// The tabular formats add an `error` column once any count is overestimated. Shares are
+// relative to the total of the top K domains.
+//
+// The counts are only exact while the number of distinct domains is within the capacity; past
+// it, the error grows with the number of rows over the capacity. A capacity below `k` uses
+// DefaultTopKCapacity, or 100 times `k` if higher, and a `k` below 1 disables the option.
+//
+// To keep the memory usage bounded, Result.Duplicates is not counted unless WithDedup or
+// WithApproximateDedup is set as well. With WithApproximateDedup, only the tracked domains
+// keep a sketch, so a domain's distinct addresses are estimated from the rows read since it
+// was last tracked, and Result.DomainSketches only covers the top K domains
+func WithTopK(k, capacity int) Option {
+	return func(c *config) {
+		if k < 1 {
+			c.topK = 0
+			return
+		}
+		if capacity < k {
+			capacity = DefaultTopKCapacity
+			if 100*k > capacity {
+				capacity = 100 * k
+			}
+		}
+
+		c.topK = k
+		c.topKCapacity = capacity
+	}
+}
+
+// WithWorkers splits the CSV data into chunks of whole records, counted by `n` goroutines in
+// parallel and merged into the same results as reading the data sequentially. An `n` below 1
+// uses one worker per CPU (see runtime.GOMAXPROCS).
+//
+// The data is still read sequentially, but counting the domains is spread across the workers.
+// It has no effect with WithTopK, as the counters depend on the order of the rows, nor with a
+// multi-byte delimiter or comment character. With WithDedup, each worker also keeps the
+// distinct addresses it counts, to merge them
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		if n < 1 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		c.workers = n
+	}
+}
+
+// WithMemoryMap reads the files opened by Parse and Count through a read-only memory mapping,
+// instead of copying them into a buffer: the records are sliced directly from the mapped pages,
+// which the kernel loads on demand and may evict once read, so the file may be larger than the
+// available memory. It only applies to regular files on Linux; otherwise (and if the file
+// cannot be mapped), the file is read through a buffer as usual.
+//
+// The file must not be truncated while it is being read, which would fault the process
+func WithMemoryMap() Option {
+	return func(c *config) {
+		c.mmap = true
+	}
+}
@@ -0,0 +1,509 @@
+package customerimporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// chunkSize is the minimum size of the chunks of CSV data counted by each worker, with WithWorkers
+const chunkSize = 1 << 20
+
+var chunkPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, chunkSize)
+		return &buf
+	},
+}
+
+// chunk is a range of whole records in the CSV data
+type chunk struct {
+	data []byte
+	// buf is the buffer from chunkPool holding the data, or nil if the data is a slice of the input
+	buf *[]byte
+	// line is the number of lines in the data before the chunk
+	line int
+}
+
+// splitState is the position of a splitter in the CSV data
+type splitState uint8
+
+const (
+	// stateRecord is the start of a record, or of a line outside of a quoted field
+	stateRecord splitState = iota
+	// stateField is the start of a field
+	stateField
+	// stateUnquoted is within an unquoted field
+	stateUnquoted
+	// stateQuoted is within a quoted field
+	stateQuoted
+	// stateQuote follows a quote within a quoted field, which either escapes a quote or ends the field
+	stateQuote
+	// stateQuoteCR follows a carriage return after the quote ending a quoted field
+	stateQuoteCR
+	// stateSkip is within a comment, or within the rest of a line discarded after a parse error
+	stateSkip
+)
+
+// splitter finds the ends of the records in CSV data, following the same rules as scanner: a
+// line feed only ends a record outside of quoted fields, and parse errors discard the rest of
+// the line. The delimiter and the comment character must be single-byte characters
+type splitter struct {
+	comma            byte
+	comment          byte
+	lazyQuotes       bool
+	trimLeadingSpace bool
+
+	state splitState
+}
+
+// scan advances through `data`, which follows the data scanned so far, returning the number of
+// bytes scanned and the offset after the last record ending in them, or -1 if none does. A
+// character split at the end of `data` may be left unscanned
+func (s *splitter) scan(data []byte) (n, end int) {
+	end = -1
+
+	for i := 0; i < len(data); i++ {
+		switch s.state {
+		case stateQuoted:
+			// only a quote ends a quoted field
+			j := bytes.IndexByte(data[i:], '"')
+			if j < 0 {
+				return len(data), end
+			}
+			i += j
+		case stateUnquoted, stateSkip:
+			// without quotes in the rest of the line, no quoted field starts before it ends
+			j := bytes.IndexByte(data[i:], '\n')
+			if j >= 0 && (s.state == stateSkip || bytes.IndexByte(data[i:i+j], '"') < 0) {
+				i += j
+			}
+		}
+
+		c := data[i]
+
+		if s.trimLeadingSpace && c >= utf8.RuneSelf && (s.state == stateRecord || s.state == stateField) {
+			// leading Unicode spaces are trimmed too, so a quote after them still starts a quoted field
+			if !utf8.FullRune(data[i:]) {
+				return i, end
+			}
+
+			r, size := utf8.DecodeRune(data[i:])
+			if unicode.IsSpace(r) {
+				s.state = stateField
+			} else {
+				s.state = stateUnquoted
+			}
+			i += size - 1
+			continue
+		}
+
+		if s.step(c) {
+			end = i + 1
+		}
+	}
+
+	return len(data), end
+}
+
+// step advances the splitter through the byte `c`, reporting whether it ends a record
+func (s *splitter) step(c byte) bool {
+	switch s.state {
+	case stateRecord:
+		if s.comment != 0 && c == s.comment {
+			s.state = stateSkip
+			return false
+		}
+		fallthrough
+	case stateField:
+		switch {
+		case c == '\n':
+			s.state = stateRecord
+			return true
+		case c == '"':
+			s.state = stateQuoted
+		case c == s.comma:
+			s.state = stateField
+		case s.trimLeadingSpace && unicode.IsSpace(rune(c)):
+			s.state = stateField
+		default:
+			s.state = stateUnquoted
+		}
+	case stateUnquoted:
+		switch {
+		case c == '\n':
+			s.state = stateRecord
+			return true
+		case c == s.comma:
+			s.state = stateField
+		case c == '"' && !s.lazyQuotes:
+			s.state = stateSkip
+		}
+	case stateQuoted:
+		if c == '"' {
+			s.state = stateQuote
+		}
+	case stateQuote:
+		switch {
+		case c == '"':
+			s.state = stateQuoted
+		case c == s.comma:
+			s.state = stateField
+		case c == '\n':
+			s.state = stateRecord
+			return true
+		case c == '\r':
+			s.state = stateQuoteCR
+		case s.lazyQuotes:
+			s.state = stateQuoted
+		default:
+			s.state = stateSkip
+		}
+	case stateQuoteCR:
+		switch {
+		case c == '\n':
+			s.state = stateRecord
+			return true
+		case s.lazyQuotes:
+			// both the quote and the carriage return are part of the field
+			s.state = stateQuoted
+			return s.step(c)
+		default:
+			s.state = stateSkip
+		}
+	case stateSkip:
+		if c == '\n' {
+			s.state = stateRecord
+			return true
+		}
+	}
+
+	return false
+}
+
+// firstRecord reads a single record from a scanner
+type firstRecord struct {
+	*scanner
+	read bool
+}
+
+func (r *firstRecord) Read() ([]string, error) {
+	if r.read {
+		return nil, io.EOF
+	}
+	r.read = true
+
+	return r.scanner.Read()
+}
+
+// scan counts the domains in the records from `sc`, in parallel when configured with WithWorkers,
+// until `ctx` is done
+func (c *counter) scan(ctx context.Context, sc *scanner) error {
+	if c.cfg.workers < 2 || c.hitters != nil || sc.comma >= utf8.RuneSelf || sc.comment >= utf8.RuneSelf {
+		return c.mapEmailRow(ctx, sc)
+	}
+
+	return c.mapParallel(ctx, sc)
+}
+
+// mapParallel reads the header from `sc`, then splits the rest of the data into chunks of whole
+// records, counted by the configured number of workers and merged into the counter. Once `ctx` is
+// done, the counts from all workers so far are merged before returning a CanceledError
+func (c *counter) mapParallel(ctx context.Context, sc *scanner) error {
+	col, err := c.readHeader(sc)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.noHeader {
+		// the first record sets the number of fields per record (see WithFieldsPerRecord), so it
+		// is counted before splitting the data
+		if err = c.mapRecords(ctx, &firstRecord{scanner: sc}, col, 0); err != nil {
+			return err
+		}
+	}
+
+	var (
+		chunks  = make(chan chunk, c.cfg.workers)
+		done    = make(chan struct{})
+		stop    sync.Once
+		wg      sync.WaitGroup
+		workers = make([]*counter, c.cfg.workers)
+		errs    = make([]error, c.cfg.workers)
+	)
+
+	for idx := range workers {
+		w := newCounter(c.cfg)
+		if c.seen != nil {
+			w.seen = map[uint64]struct{}{}
+		}
+		if c.occurrences != nil {
+			w.origins = map[uint64]origin{}
+		}
+		workers[idx] = w
+
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			r := sc.fork()
+			for ch := range chunks {
+				r.resetBytes(ch.data, ch.line)
+				err := w.mapRecords(ctx, r, col, 1)
+
+				if ch.buf != nil {
+					*ch.buf = (*ch.buf)[:0]
+					chunkPool.Put(ch.buf)
+				}
+
+				if err != nil {
+					// the remaining chunks only hold later rows
+					errs[idx] = err
+					stop.Do(func() { close(done) })
+					return
+				}
+			}
+		}(idx)
+	}
+
+	err = c.split(ctx, sc, chunks, done)
+	wg.Wait()
+
+	// rows with errors were read before any error reading the data
+	if rowErr := firstError(errs); rowErr != nil {
+		return rowErr
+	}
+	if err != nil && err != ctx.Err() {
+		return err
+	}
+
+	for _, w := range workers {
+		c.merge(w)
+	}
+
+	// either the split or any of the workers may have been stopped by the context
+	stopped := err != nil
+	for _, workerErr := range errs {
+		stopped = stopped || isCanceled(workerErr)
+	}
+	if stopped {
+		return c.canceled(ctx)
+	}
+
+	return nil
+}
+
+// split reads the rest of the data in `sc` into chunks of whole records, sent to `chunks` until
+// the data is exhausted or `done` is closed. Returns an error if the data cannot be read, or the
+// context's error if `ctx` is done first
+func (c *counter) split(ctx context.Context, sc *scanner, chunks chan<- chunk, done <-chan struct{}) error {
+	defer close(chunks)
+
+	sp := &splitter{
+		comma:            byte(sc.comma),
+		comment:          byte(sc.comment),
+		lazyQuotes:       sc.lazyQuotes,
+		trimLeadingSpace: sc.trimLeadingSpace,
+	}
+
+	send := func(ch chunk) bool {
+		select {
+		case chunks <- ch:
+			return true
+		case <-done:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if sc.r == nil {
+		if !splitBytes(sp, sc.data[sc.off:], sc.numLine, send) {
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	var (
+		buf  = chunkPool.Get().(*[]byte)
+		line = sc.numLine
+		// scanned is the number of bytes in buf already scanned, and end the offset after the
+		// last record ending in them
+		scanned, end int
+	)
+
+	for {
+		data := *buf
+		n, err := io.ReadFull(sc.r, data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		*buf = data
+
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			if len(data) > 0 && !send(chunk{data: data, buf: buf, line: line}) {
+				return ctx.Err()
+			}
+			return nil
+		default:
+			return err
+		}
+
+		n, last := sp.scan(data[scanned:])
+		if last >= 0 {
+			end = scanned + last
+		}
+		scanned += n
+
+		if end == 0 {
+			// no record ends in the buffer yet
+			grown := make([]byte, len(data), 2*cap(data))
+			copy(grown, data)
+			*buf = grown
+			continue
+		}
+
+		next := chunkPool.Get().(*[]byte)
+		*next = append(*next, data[end:]...)
+
+		if !send(chunk{data: data[:end], buf: buf, line: line}) {
+			return ctx.Err()
+		}
+
+		line += bytes.Count(data[:end], []byte{'\n'})
+		buf = next
+		scanned -= end
+		end = 0
+	}
+}
+
+// splitBytes splits the in-memory `data`, following line number `line`, into chunks of whole
+// records sliced from it, passed to `send` until it returns false. Reports whether all the data
+// was sent
+func splitBytes(sp *splitter, data []byte, line int, send func(chunk) bool) bool {
+	var (
+		// limit is the size of the data to scan for the end of a record
+		limit = chunkSize
+		// scanned is the number of bytes in data already scanned, and end the offset after the
+		// last record ending in them
+		scanned, end int
+	)
+
+	for {
+		if limit >= len(data) {
+			return len(data) == 0 || send(chunk{data: data, line: line})
+		}
+		if limit < scanned {
+			limit = scanned
+		}
+
+		n, last := sp.scan(data[scanned:limit])
+		if last >= 0 {
+			end = scanned + last
+		}
+		scanned += n
+
+		if end == 0 {
+			// no record ends within the limit yet
+			limit *= 2
+			continue
+		}
+
+		if !send(chunk{data: data[:end], line: line}) {
+			return false
+		}
+
+		line += bytes.Count(data[:end], []byte{'\n'})
+		data = data[end:]
+		scanned -= end
+		end = 0
+		limit = chunkSize
+	}
+}
+
+// firstError returns the error for the earliest row among the workers' errors `errs`, ignoring
+// the workers stopped by their context
+func firstError(errs []error) error {
+	var (
+		first     error
+		firstLine int
+	)
+
+	for _, err := range errs {
+		if err == nil || isCanceled(err) {
+			continue
+		}
+
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) {
+			return err
+		}
+		if first == nil || rowErr.Line < firstLine {
+			first = err
+			firstLine = rowErr.Line
+		}
+	}
+
+	return first
+}
+
+// merge adds the counts from the parallel worker `w` to the counter
+func (c *counter) merge(w *counter) {
+	c.rows += w.rows
+	c.duplicates += w.duplicates
+	if w.line > c.line {
+		c.line = w.line
+	}
+	c.skipped.merge(w.skipped, c.errorLimit())
+
+	for domain, count := range w.domains {
+		c.domains[domain] += count
+	}
+	for canonical, aliases := range w.aliased {
+		for alias, count := range aliases {
+			c.fold(alias, canonical, count)
+		}
+	}
+
+	for h := range w.seen {
+		if _, ok := c.seen[h]; ok {
+			c.duplicates++
+			continue
+		}
+		c.seen[h] = struct{}{}
+	}
+
+	for domain, count := range w.unique {
+		c.unique[domain] += count
+	}
+	for h, address := range w.repeated {
+		c.repeated[h] = address
+	}
+	for h, n := range w.occurrences {
+		prev := c.occurrences[h]
+		c.occurrences[h] = prev + n
+		if prev == 0 {
+			continue
+		}
+
+		// the address was already counted as distinct, in another part of the data
+		o := w.origins[h]
+		c.duplicates++
+		c.unique[o.domain]--
+		if _, ok := c.repeated[h]; !ok {
+			c.repeated[h] = o.address
+		}
+	}
+
+	if w.sketch != nil {
+		// sketches in a counter share the same precision
+		_ = c.sketch.Merge(w.sketch)
+		for domain, s := range w.sketches {
+			c.mergeSketch(c.sketches, domain, s)
+		}
+	}
+}
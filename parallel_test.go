@@ -0,0 +1,200 @@
+package customerimporter_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+// parallelInput returns several copies of the customers.csv data (so the data spans several
+// chunks and repeats addresses across them), with CRLF line endings, multi-line quoted fields,
+// comments, repeated headers and invalid rows mixed in
+func parallelInput(t *testing.T) []byte {
+	data, err := os.ReadFile("./testdata/customers.csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	buf := &bytes.Buffer{}
+	buf.WriteString(lines[0] + "\n")
+
+	for copies := 0; copies < 12; copies++ {
+		for idx, line := range lines[1:] {
+			switch idx % 97 {
+			case 1:
+				fmt.Fprintf(buf, "\"first\nand \"\"second\"\",\nline\",x,%s,Other,0.0.0.0\n", strings.Split(line, ",")[2])
+			case 2:
+				buf.WriteString(line + "\r\n")
+			case 3:
+				fmt.Fprintf(buf, "# copy %d, \"row\" %d\n", copies, idx)
+			case 4:
+				buf.WriteString("jane,doe,not-an-address,Female,0.0.0.0\n")
+			case 5:
+				buf.WriteString("jane,doe,\"broken\"quote@example.com,Female,0.0.0.0\n")
+			case 6:
+				buf.WriteString("jane,doe\n")
+			case 7:
+				buf.WriteString(lines[0] + "\n")
+			default:
+				buf.WriteString(line + "\n")
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestWorkers(t *testing.T) {
+	input := parallelInput(t)
+
+	aliases := NewAliasMap()
+	if err := aliases.Add("github.io", "github.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "Default",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCollect), WithMaxErrors(50)},
+		},
+		{
+			name: "SkipAndCount",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCount), WithSort(SortByCountDesc)},
+		},
+		{
+			name: "Dedup",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCount), WithDedup(), WithAliases(aliases)},
+		},
+		{
+			name: "ApproximateDedup",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCount), WithApproximateDedup(0.02)},
+		},
+		{
+			name: "Rollup",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCount), WithRollup(nil), WithTypoCorrection(nil)},
+		},
+		{
+			name: "NoHeader",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCount), WithColumnIndex(2), WithStrictValidation()},
+		},
+		{
+			name: "LazyQuotes",
+			opts: []Option{WithComment('#'), WithErrorPolicy(SkipAndCollect), WithLazyQuotes(), WithTrimLeadingSpace()},
+		},
+		{
+			name: "FailFast",
+			opts: []Option{WithComment('#')},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			wanted, wantedErr := CountReader(bytes.NewReader(input), testcase.opts...)
+
+			for _, workers := range []int{2, 3, 8} {
+				res, err := CountReader(bytes.NewReader(input), append(testcase.opts, WithWorkers(workers))...)
+				if wantedErr != nil || err != nil {
+					var wantedRowErr, rowErr *RowError
+					if !errors.As(wantedErr, &wantedRowErr) || !errors.As(err, &rowErr) || *wantedRowErr != *rowErr {
+						t.Errorf("unexpected error with %d workers: wanted %v ; got %v", workers, wantedErr, err)
+					}
+					continue
+				}
+
+				if wanted.Sketch != nil {
+					if wanted.Sketch.Estimate() != res.Sketch.Estimate() {
+						t.Errorf("estimate mismatch error with %d workers: wanted %v ; got %v", workers, wanted.Sketch.Estimate(), res.Sketch.Estimate())
+					}
+					for domain, s := range wanted.DomainSketches {
+						if s.Estimate() != res.DomainSketches[domain].Estimate() {
+							t.Errorf("estimate mismatch error for %q with %d workers", domain, workers)
+						}
+					}
+				}
+
+				got, want := *res, *wanted
+				got.Elapsed, want.Elapsed = 0, 0
+				got.Sketch, want.Sketch = nil, nil
+				got.DomainSketches, want.DomainSketches = nil, nil
+
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("output mismatch error with %d workers: wanted %d rows, %d invalid, %d duplicates and %d entries ; got %d rows, %d invalid, %d duplicates and %d entries",
+						workers, want.Rows, want.Invalid, want.Duplicates, len(want.Entries), got.Rows, got.Invalid, got.Duplicates, len(got.Entries))
+				}
+			}
+		})
+	}
+
+	t.Run("DedupDomainWithAt", func(t *testing.T) {
+		// without normalization, the domain is everything after the first '@', so it cannot be
+		// recovered from the address by its last '@'
+		buf := &bytes.Buffer{}
+		buf.WriteString("name,email\n")
+		for idx := 0; idx < 200000; idx++ {
+			fmt.Fprintf(buf, "john,john@b@c.com\njane%d,jane@example%d.com\n", idx, idx%7)
+		}
+
+		opts := []Option{WithNormalization(NormNone), WithDedup()}
+		wanted, err := CountReader(bytes.NewReader(buf.Bytes()), opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		res, err := CountReader(bytes.NewReader(buf.Bytes()), append(opts, WithWorkers(4))...)
+		if err != nil {
+			t.Errorf("unexpected error: wanted nil ; got %v", err)
+			return
+		}
+
+		res.Elapsed, wanted.Elapsed = 0, 0
+		if !reflect.DeepEqual(wanted, res) {
+			t.Errorf("output mismatch error: wanted %+v ; got %+v", wanted.Entries, res.Entries)
+		}
+	})
+
+	t.Run("FailFastLaterChunk", func(t *testing.T) {
+		// only the last rows are invalid, so the earliest error is in the last chunk
+		raw, err := os.ReadFile("./testdata/customers.csv")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		header := bytes.IndexByte(raw, '\n') + 1
+		data := append(raw[:header:header], bytes.Repeat(raw[header:], 10)...)
+		line := bytes.Count(data, []byte{'\n'}) + 1
+		data = append(data, "jane,doe,not-an-address,Female,0.0.0.0\njohn,doe,,Male,0.0.0.0\n"...)
+
+		_, wanted := ParseReader(bytes.NewReader(data))
+		_, err = ParseReader(bytes.NewReader(data), WithWorkers(4))
+
+		var wantedRowErr, rowErr *RowError
+		if !errors.As(wanted, &wantedRowErr) || !errors.As(err, &rowErr) || *wantedRowErr != *rowErr {
+			t.Errorf("unexpected error: wanted %v ; got %v", wanted, err)
+		}
+		if rowErr != nil && rowErr.Line != line {
+			t.Errorf("line mismatch error: wanted %d ; got %d", line, rowErr.Line)
+		}
+	})
+
+	t.Run("Small", func(t *testing.T) {
+		for _, input := range []string{"", "email\n", "email\r\na@b.com", "email\n\"a@\nb.com\",x\n"} {
+			wanted, wantedErr := ParseReader(strings.NewReader(input), WithFieldsPerRecord(-1))
+			entries, err := ParseReader(strings.NewReader(input), WithFieldsPerRecord(-1), WithWorkers(0))
+
+			if !errors.Is(err, wantedErr) && (err == nil || wantedErr == nil || err.Error() != wantedErr.Error()) {
+				t.Errorf("unexpected error for %q: wanted %v ; got %v", input, wantedErr, err)
+			}
+			if !reflect.DeepEqual(wanted, entries) {
+				t.Errorf("output mismatch error for %q: wanted %v ; got %v", input, wanted, entries)
+			}
+		}
+	})
+}
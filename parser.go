@@ -0,0 +1,316 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// sniffSize is the amount of bytes inspected when detecting the delimiter
+	sniffSize = 4096
+	// sniffLines is the maximum number of lines inspected when detecting the delimiter
+	sniffLines = 10
+)
+
+// sniffCandidates lists the delimiters considered when detecting the delimiter, in order
+// of preference when tied
+var sniffCandidates = []rune{',', ';', '\t', '|'}
+
+// Parser counts the domains in email addresses from CSV data, with a reusable configuration.
+// A Parser is safe for concurrent use
+type Parser struct {
+	cfg config
+}
+
+// New creates a Parser configured with the input Options
+func New(opts ...Option) *Parser {
+	return &Parser{cfg: newConfig(opts...)}
+}
+
+// Parse reads a CSV file from `path` in the filesystem to extract the number of occurrences
+// for each present domain. Returns a slice of Entry and an error
+func (p *Parser) Parse(path string) ([]Entry, error) {
+	return p.ParseContext(context.Background(), path)
+}
+
+// ParseContext is like Parse, but stops once `ctx` is done, checking it between records. When
+// stopped, it returns the entries counted so far along with a CanceledError
+func (p *Parser) ParseContext(ctx context.Context, path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc, release, err := p.newFileReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.parse(ctx, sc)
+}
+
+// ParseReader reads CSV data from the io.Reader `r` to extract the number of occurrences
+// for each present domain, one record at a time. Returns a slice of Entry and an error
+func (p *Parser) ParseReader(r io.Reader) ([]Entry, error) {
+	return p.ParseReaderContext(context.Background(), r)
+}
+
+// ParseReaderContext is like ParseReader, but stops once `ctx` is done, checking it between
+// records. When stopped, it returns the entries counted so far along with a CanceledError
+func (p *Parser) ParseReaderContext(ctx context.Context, r io.Reader) ([]Entry, error) {
+	sc, err := p.newCSVReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parse(ctx, sc)
+}
+
+func (p *Parser) parse(ctx context.Context, sc *scanner) ([]Entry, error) {
+	c := newCounter(p.cfg)
+	err := c.scan(ctx, sc)
+	if err != nil && !isCanceled(err) {
+		return nil, err
+	}
+
+	entries := c.entries(ctx)
+	if err == nil && c.cfg.dns != nil {
+		// the DNS lookups are not conclusive once the context is done
+		err = c.canceled(ctx)
+	}
+
+	return entries, err
+}
+
+// Count reads a CSV file from `path` in the filesystem to extract the number of occurrences
+// for each present domain. Returns a Result with the sorted entries and the parsing totals,
+// and an error
+func (p *Parser) Count(path string) (*Result, error) {
+	return p.CountContext(context.Background(), path)
+}
+
+// CountContext is like Count, but stops once `ctx` is done, checking it between records. When
+// stopped, it returns a Result with the totals counted so far along with a CanceledError
+func (p *Parser) CountContext(ctx context.Context, path string) (*Result, error) {
+	start := time.Now()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc, release, err := p.newFileReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.count(ctx, sc, path, start)
+}
+
+// CountReader reads CSV data from the io.Reader `r` to extract the number of occurrences
+// for each present domain, one record at a time. Returns a Result with the sorted entries
+// and the parsing totals, and an error. If `r` has a `Name() string` method (like an
+// os.File), it is used as the Result's source
+func (p *Parser) CountReader(r io.Reader) (*Result, error) {
+	return p.CountReaderContext(context.Background(), r)
+}
+
+// CountReaderContext is like CountReader, but stops once `ctx` is done, checking it between
+// records. When stopped, it returns a Result with the totals counted so far along with a
+// CanceledError
+func (p *Parser) CountReaderContext(ctx context.Context, r io.Reader) (*Result, error) {
+	start := time.Now()
+
+	var source string
+	if named, ok := r.(interface{ Name() string }); ok {
+		source = named.Name()
+	}
+
+	sc, err := p.newCSVReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.count(ctx, sc, source, start)
+}
+
+func (p *Parser) count(ctx context.Context, sc *scanner, source string, start time.Time) (*Result, error) {
+	c := newCounter(p.cfg)
+	c.trackDuplicates()
+	err := c.scan(ctx, sc)
+	if err != nil && !isCanceled(err) {
+		return nil, err
+	}
+
+	res := newResult(ctx, c, source, time.Since(start))
+	if err == nil && c.cfg.dns != nil {
+		// the DNS lookups are not conclusive once the context is done
+		err = c.canceled(ctx)
+	}
+
+	return res, err
+}
+
+// newFileReader creates a scanner for the file `f`, reading it through a memory mapping when
+// configured with WithMemoryMap and supported, or through a buffer otherwise. The returned
+// release function must be called once the scanner is no longer in use
+func (p *Parser) newFileReader(f *os.File) (*scanner, func() error, error) {
+	if p.cfg.mmap {
+		if data, unmap, err := mmapFile(f); err == nil {
+			sc, err := p.newBytesCSVReader(data)
+			if err != nil {
+				_ = unmap()
+				return nil, nil, err
+			}
+
+			return sc, unmap, nil
+		}
+	}
+
+	sc, err := p.newCSVReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sc, func() error { return nil }, nil
+}
+
+func (p *Parser) newCSVReader(r io.Reader) (*scanner, error) {
+	comma := p.cfg.comma
+
+	if p.cfg.sniff {
+		br := bufio.NewReaderSize(r, sniffSize)
+
+		// Peek returns what it could read along with io.EOF / bufio.ErrBufferFull on
+		// short or long inputs; both are expected here
+		head, err := br.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+
+		comma = p.sniff(head)
+		r = br
+	}
+
+	sc, err := newScanner(r, comma, p.cfg.comment)
+	if err != nil {
+		return nil, err
+	}
+	p.configure(sc)
+
+	return sc, nil
+}
+
+// newBytesCSVReader creates a scanner reading the in-memory `data` directly
+func (p *Parser) newBytesCSVReader(data []byte) (*scanner, error) {
+	comma := p.cfg.comma
+
+	if p.cfg.sniff {
+		head := data
+		if len(head) > sniffSize {
+			head = head[:sniffSize]
+		}
+
+		comma = p.sniff(head)
+	}
+
+	sc, err := newBytesScanner(data, comma, p.cfg.comment)
+	if err != nil {
+		return nil, err
+	}
+	p.configure(sc)
+
+	return sc, nil
+}
+
+// sniff returns the delimiter detected in `head`, or the configured one if none is detected
+func (p *Parser) sniff(head []byte) rune {
+	if c, ok := sniffDelimiter(head, p.cfg.comment); ok {
+		return c
+	}
+
+	return p.cfg.comma
+}
+
+func (p *Parser) configure(sc *scanner) {
+	sc.lazyQuotes = p.cfg.lazyQuotes
+	sc.trimLeadingSpace = p.cfg.trimLeadingSpace
+	sc.fieldsPerRecord = p.cfg.fieldsPerRecord
+}
+
+// sniffDelimiter inspects the first lines in `head` and returns the candidate delimiter
+// that appears the same (non-zero) number of times, outside of quotes, in every line.
+// When several candidates qualify, the one with most occurrences per line wins
+func sniffDelimiter(head []byte, comment rune) (rune, bool) {
+	lines := bytes.Split(head, []byte{'\n'})
+	// the last line is likely truncated, unless it is the whole input
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		best      rune
+		bestCount int
+	)
+
+	for _, candidate := range sniffCandidates {
+		count := -1
+		inspected := 0
+
+		for _, line := range lines {
+			if inspected == sniffLines {
+				break
+			}
+
+			line = bytes.TrimRight(line, "\r")
+			if len(line) == 0 || (comment != 0 && hasRunePrefix(line, comment)) {
+				continue
+			}
+			inspected++
+
+			n := countUnquoted(line, candidate)
+			if count == -1 {
+				count = n
+				continue
+			}
+			if n != count {
+				count = 0
+				break
+			}
+		}
+
+		if count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+
+	return best, bestCount > 0
+}
+
+// countUnquoted returns the number of times the single-byte `sep` occurs in `line`,
+// outside of double-quoted fields
+func countUnquoted(line []byte, sep rune) int {
+	var (
+		count  int
+		quoted bool
+	)
+
+	for _, b := range line {
+		switch {
+		case b == '"':
+			quoted = !quoted
+		case !quoted && rune(b) == sep:
+			count++
+		}
+	}
+
+	return count
+}
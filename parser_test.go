@@ -0,0 +1,103 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestParser(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		for _, testcase := range []struct {
+			name  string
+			input string
+			opts  []Option
+		}{
+			{
+				name:  "Semicolon",
+				input: "name;email\njohn;john@example.com\njane;jane@example.com\n",
+				opts:  []Option{WithComma(';')},
+			},
+			{
+				name:  "Tab",
+				input: "name\temail\njohn\tjohn@example.com\njane\tjane@example.com\n",
+				opts:  []Option{WithComma('\t')},
+			},
+			{
+				name:  "Comment",
+				input: "# exported on 2022-01-01\nname,email\njohn,john@example.com\n# page 2\njane,jane@example.com\n",
+				opts:  []Option{WithComment('#')},
+			},
+			{
+				name:  "LazyQuotes",
+				input: "name,email\njohn \"jd\" doe,john@example.com\njane,jane@example.com\n",
+				opts:  []Option{WithLazyQuotes()},
+			},
+			{
+				name:  "TrimLeadingSpace",
+				input: "name, email\njohn,  john@example.com\njane, jane@example.com\n",
+				opts:  []Option{WithTrimLeadingSpace()},
+			},
+			{
+				name:  "VariableFields",
+				input: "name,email\njohn,john@example.com,extra\njane,jane@example.com\n",
+				opts:  []Option{WithFieldsPerRecord(-1)},
+			},
+			{
+				name:  "DetectSemicolon",
+				input: "name;email;notes\njohn;john@example.com;\"a, b, c\"\njane;jane@example.com;\n",
+				opts:  []Option{WithDelimiterDetection()},
+			},
+			{
+				name:  "DetectTab",
+				input: "name\temail\r\njohn\tjohn@example.com\r\njane\tjane@example.com\r\n",
+				opts:  []Option{WithDelimiterDetection()},
+			},
+			{
+				name:  "DetectPipe",
+				input: "name|email\njohn|john@example.com\njane|jane@example.com",
+				opts:  []Option{WithDelimiterDetection()},
+			},
+			{
+				name:  "DetectWithMultibyteComment",
+				input: "§ exported, with a comma\nname;email\njohn;john@example.com\njane;jane@example.com\n",
+				opts:  []Option{WithDelimiterDetection(), WithComment('§')},
+			},
+			{
+				name:  "DetectFallback",
+				input: "email\njohn@example.com\njane@example.com\n",
+				opts:  []Option{WithDelimiterDetection()},
+			},
+		} {
+			t.Run(testcase.name, func(t *testing.T) {
+				entries, err := New(testcase.opts...).ParseReader(strings.NewReader(testcase.input))
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				if len(entries) != 1 || entries[0].Domain != "example.com" || entries[0].Count != 2 {
+					t.Errorf("output mismatch error: wanted [{2 example.com}] ; got %v", entries)
+				}
+			})
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		t.Run("WrongDelimiter", func(t *testing.T) {
+			_, err := New(WithComma(';')).ParseReader(strings.NewReader("name,email\njohn,john@example.com\n"))
+			if !errors.Is(err, ErrColumnNotFound) {
+				t.Errorf("unexpected error: wanted %v ; got %v", ErrColumnNotFound, err)
+			}
+		})
+
+		t.Run("InvalidDelimiter", func(t *testing.T) {
+			_, err := New(WithComma('\n')).ParseReader(strings.NewReader("name,email\njohn,john@example.com\n"))
+			if err == nil {
+				t.Error("expected an error; got nil")
+			}
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package customerimporter
+
+import (
+	"context"
+	"time"
+)
+
+// Result describes the outcome of parsing CSV data: the sorted entries for each domain,
+// alongside the totals gathered while reading the data
+type Result struct {
+	// Source is the name of the parsed input, such as the file path
+	Source string
+	// Entries lists the count for each domain, sorted according to the configured SortOrder
+	Entries []Entry
+	// Rows is the number of data rows read, excluding headers
+	Rows int
+	// Valid is the number of rows with a valid email address, counted in Entries
+	Valid int
+	// Invalid is the number of rows skipped according to the ErrorPolicy
+	Invalid int
+	// Duplicates is the number of valid rows whose email address had already been read. It
+	// is estimated with WithApproximateDedup. With WithTopK, it is only counted along with
+	// WithDedup or WithApproximateDedup, to keep the memory usage bounded
+	Duplicates int
+	// DuplicateAddresses lists the addresses found in more than one row, by descending count,
+	// with WithDedup
+	DuplicateAddresses []AddressCount
+	// Skipped details the invalid rows, according to the ErrorPolicy
+	Skipped SkipReport
+	// Typos lists the likely misspelled domains, with WithTypoDetection or WithTypoCorrection
+	Typos []Typo
+	// Sketch estimates the distinct addresses overall, with WithApproximateDedup
+	Sketch *Sketch
+	// DomainSketches estimate the distinct addresses for each domain (as in Entries, or their
+	// Children when rolling up), with WithApproximateDedup
+	DomainSketches map[string]*Sketch
+	// ErrorBound is the maximum count of any domain left out of Entries, with WithTopK: the
+	// count of the next tracked domain, or the maximum overestimation of any tracked count
+	// once domains were evicted, whichever is higher
+	ErrorBound int
+	// Elapsed is the time taken to read and count the data
+	Elapsed time.Duration
+
+	index map[string]int
+}
+
+func newResult(ctx context.Context, c *counter, source string, elapsed time.Duration) *Result {
+	entries := c.entries(ctx)
+
+	duplicates := c.duplicates
+	if c.sketch != nil {
+		valid := c.rows - c.skipped.Count
+		if unique := int(c.sketch.Estimate()); unique < valid {
+			duplicates = valid - unique
+		}
+	}
+
+	r := &Result{
+		Source:     source,
+		Entries:    entries,
+		Rows:       c.rows,
+		Valid:      c.rows - c.skipped.Count,
+		Invalid:    c.skipped.Count,
+		Duplicates: duplicates,
+		Skipped:    c.skipped,
+		Typos:      c.typos,
+		Elapsed:    elapsed,
+
+		DuplicateAddresses: c.duplicateAddresses(),
+		Sketch:             c.sketch,
+		DomainSketches:     c.sketches,
+	}
+	if c.hitters != nil {
+		r.ErrorBound = c.leftOut
+	}
+	r.reindex()
+
+	return r
+}
+
+// reindex maps each domain to its position in Entries
+func (r *Result) reindex() {
+	r.index = make(map[string]int, len(r.Entries))
+	for idx, e := range r.Entries {
+		r.index[e.Domain] = idx
+	}
+}
+
+// Distinct returns the number of distinct domains
+func (r *Result) Distinct() int {
+	return len(r.Entries)
+}
+
+// Total returns the sum of the counts for all domains. With WithTopK, it only covers the
+// top K domains
+func (r *Result) Total() int {
+	var total int
+	for _, e := range r.Entries {
+		total += e.Count
+	}
+	return total
+}
+
+// Lookup returns the Entry for `domain`, and whether it is present
+func (r *Result) Lookup(domain string) (Entry, bool) {
+	if r.index == nil {
+		r.reindex()
+	}
+
+	idx, ok := r.index[domain]
+	if !ok {
+		return Entry{}, false
+	}
+	return r.Entries[idx], true
+}
+
+// Top returns the `n` domains with the highest counts, in descending order. Ties are
+// broken by domain name. If `n` is not positive or exceeds the number of domains, all
+// domains are returned
+func (r *Result) Top(n int) []Entry {
+	top := make([]Entry, len(r.Entries))
+	copy(top, r.Entries)
+	SortEntries(top, SortByCountDesc)
+
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// Share returns the percentage (from 0 to 100) of the total count that belongs to `domain`.
+// With WithTopK, it is relative to the total of the top K domains, not to the whole input
+func (r *Result) Share(domain string) float64 {
+	e, ok := r.Lookup(domain)
+	if !ok {
+		return 0
+	}
+
+	total := r.Total()
+	if total == 0 {
+		return 0
+	}
+	return float64(e.Count) * 100 / float64(total)
+}
+
+// DisposableSummary describes how many addresses in a Result belong to disposable email providers
+type DisposableSummary struct {
+	// Domains is the number of disposable domains
+	Domains int `json:"domains"`
+	// Count is the number of addresses in disposable domains
+	Count int `json:"count"`
+	// Share is the percentage of the total count in disposable domains, rounded to 4 decimal places
+	Share float64 `json:"share"`
+}
+
+// Disposable summarizes the entries flagged as disposable email providers. It is only
+// meaningful when parsing with WithDisposableDomains
+func (r *Result) Disposable() DisposableSummary {
+	var summary DisposableSummary
+
+	for _, e := range r.Entries {
+		if e.Disposable {
+			summary.Domains++
+			summary.Count += e.Count
+			continue
+		}
+
+		// a rolled-up entry may hold disposable subdomains of a regular registrable domain
+		for _, child := range e.Children {
+			if child.Disposable {
+				summary.Domains++
+				summary.Count += child.Count
+			}
+		}
+	}
+
+	summary.Share = percentage(summary.Count, r.Total())
+	return summary
+}
@@ -0,0 +1,93 @@
+package customerimporter_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestResult(t *testing.T) {
+	t.Run("FromFile", func(t *testing.T) {
+		res, err := Count(rawPath)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if res.Source != rawPath {
+			t.Errorf("source mismatch: wanted %q ; got %q", rawPath, res.Source)
+		}
+		if res.Rows != 3000 || res.Valid != 3000 || res.Invalid != 0 || res.Duplicates != 0 {
+			t.Errorf("totals mismatch: wanted 3000 rows, 3000 valid, 0 invalid, 0 duplicates ; got %d, %d, %d, %d",
+				res.Rows, res.Valid, res.Invalid, res.Duplicates)
+		}
+		if res.Distinct() != len(expectedResults) {
+			t.Errorf("distinct domains mismatch: wanted %d ; got %d", len(expectedResults), res.Distinct())
+		}
+		if res.Total() != 3000 {
+			t.Errorf("total mismatch: wanted %d ; got %d", 3000, res.Total())
+		}
+		if res.Elapsed <= 0 {
+			t.Errorf("expected a positive elapsed time; got %v", res.Elapsed)
+		}
+
+		for domain, count := range expectedResults {
+			e, ok := res.Lookup(domain)
+			if !ok || e.Count != count {
+				t.Errorf("lookup mismatch for %s: wanted %d ; got %d (found: %v)", domain, count, e.Count, ok)
+			}
+		}
+
+		top := res.Top(3)
+		wants := []Entry{
+			{Count: 14, Domain: "loc.gov"},
+			{Count: 13, Domain: "domainmarket.com"},
+			{Count: 13, Domain: "reddit.com"},
+		}
+		if len(top) != len(wants) {
+			t.Errorf("top length mismatch: wanted %d ; got %d", len(wants), len(top))
+			return
+		}
+		for idx := range wants {
+			if top[idx].Domain != wants[idx].Domain || top[idx].Count != wants[idx].Count {
+				t.Errorf("top entry #%d mismatch: wanted %v ; got %v", idx, wants[idx], top[idx])
+			}
+		}
+	})
+
+	t.Run("FromReader", func(t *testing.T) {
+		const input = `email
+john@example.com
+jane@example.com
+john@example.com
+jim@example.org
+invalid
+`
+		res, err := CountReader(strings.NewReader(input), WithErrorPolicy(SkipAndCount))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if res.Rows != 5 || res.Valid != 4 || res.Invalid != 1 || res.Duplicates != 1 {
+			t.Errorf("totals mismatch: wanted 5 rows, 4 valid, 1 invalid, 1 duplicate ; got %d, %d, %d, %d",
+				res.Rows, res.Valid, res.Invalid, res.Duplicates)
+		}
+		if res.Skipped.Reasons[ErrInvalidDomain] != 1 {
+			t.Errorf("unexpected skip reasons: %v", res.Skipped.Reasons)
+		}
+		if share := res.Share("example.com"); share != 75 {
+			t.Errorf("share mismatch: wanted %v ; got %v", 75.0, share)
+		}
+		if share := res.Share("missing.com"); share != 0 {
+			t.Errorf("share mismatch: wanted %v ; got %v", 0.0, share)
+		}
+		if _, ok := res.Lookup("missing.com"); ok {
+			t.Error("expected lookup to fail for a missing domain")
+		}
+		if top := res.Top(0); len(top) != 2 || top[0].Domain != "example.com" {
+			t.Errorf("unexpected top entries: %v", top)
+		}
+	})
+}
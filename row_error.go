@@ -0,0 +1,110 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// defaultMaxErrors is the number of RowError collected with the SkipAndCollect policy,
+// when not configured with WithMaxErrors
+const defaultMaxErrors = 100
+
+// ErrorPolicy defines how invalid rows in the CSV data are handled
+type ErrorPolicy uint8
+
+const (
+	// FailFast aborts parsing on the first invalid row, returning its RowError. This is the default
+	FailFast ErrorPolicy = iota
+	// SkipAndCount skips invalid rows, only counting them (per reason) in the SkipReport
+	SkipAndCount
+	// SkipAndCollect skips invalid rows, counting them and collecting their RowError in the
+	// SkipReport, up to a limit (see WithMaxErrors)
+	SkipAndCollect
+)
+
+// RowError describes an invalid row in the CSV data, with its line number, the raw value
+// in the email column and the reason it was rejected. It wraps the reason, so
+// `errors.Is(err, ErrInvalidDomain)` reports whether a RowError was caused by ErrInvalidDomain
+type RowError struct {
+	Line  int
+	Value string
+	Err   error
+}
+
+// Error implements the error interface
+func (e *RowError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %q: %v", e.Line, e.Value, e.Err)
+}
+
+// Unwrap returns the reason for the RowError
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// SkipReport summarizes the rows skipped with the SkipAndCount and SkipAndCollect policies
+type SkipReport struct {
+	// Count is the total number of skipped rows
+	Count int
+	// Reasons maps each reason (such as ErrInvalidDomain or csv.ErrFieldCount) to the number
+	// of rows skipped because of it
+	Reasons map[error]int
+	// Errors lists the RowError for the first skipped rows, with the SkipAndCollect policy
+	Errors []*RowError
+}
+
+// add records the skipped row `rowErr`, collecting it if there is still room for `limit` errors
+func (s *SkipReport) add(rowErr *RowError, limit int) {
+	if s.Reasons == nil {
+		s.Reasons = map[error]int{}
+	}
+
+	s.Count++
+	s.Reasons[rowErr.Err]++
+
+	if limit > 0 && len(s.Errors) < limit {
+		s.Errors = append(s.Errors, rowErr)
+	}
+}
+
+// merge adds the skipped rows in `other` to the report, keeping the RowError for the first
+// `limit` skipped rows, by line number
+func (s *SkipReport) merge(other SkipReport, limit int) {
+	if other.Count == 0 {
+		return
+	}
+	if s.Reasons == nil {
+		s.Reasons = map[error]int{}
+	}
+
+	s.Count += other.Count
+	for reason, n := range other.Reasons {
+		s.Reasons[reason] += n
+	}
+
+	if len(other.Errors) == 0 {
+		return
+	}
+	s.Errors = append(s.Errors, other.Errors...)
+	sort.SliceStable(s.Errors, func(i, j int) bool {
+		return s.Errors[i].Line < s.Errors[j].Line
+	})
+	if len(s.Errors) > limit {
+		s.Errors = s.Errors[:limit]
+	}
+}
+
+// newParseRowError converts a csv.ParseError into a RowError, keyed by the csv package's
+// sentinel error. Returns false if `err` is not a csv.ParseError
+func newParseRowError(err error) (*RowError, bool) {
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) {
+		return nil, false
+	}
+
+	return &RowError{Line: parseErr.Line, Err: parseErr.Err}, true
+}
@@ -0,0 +1,95 @@
+package customerimporter_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+const invalidRowsInput = `first_name,last_name,email
+john,doe,john@example.com
+jane,doe,janeexample.com
+jim,doe,jim@example.com,extra
+joe,doe,joeexample.com
+jill,doe,jill@example.com
+`
+
+func TestErrorPolicy(t *testing.T) {
+	t.Run("FailFast", func(t *testing.T) {
+		_, err := ParseReader(strings.NewReader(invalidRowsInput))
+		if !errors.Is(err, ErrInvalidDomain) {
+			t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidDomain, err)
+			return
+		}
+
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) {
+			t.Errorf("expected a RowError; got %T", err)
+			return
+		}
+		if rowErr.Line != 3 || rowErr.Value != "janeexample.com" {
+			t.Errorf("unexpected row error: wanted line 3 with value %q ; got line %d with value %q",
+				"janeexample.com", rowErr.Line, rowErr.Value)
+		}
+	})
+
+	t.Run("SkipAndCount", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(invalidRowsInput),
+			WithErrorPolicy(SkipAndCount),
+		)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if len(res.Entries) != 1 || res.Entries[0].Count != 2 {
+			t.Errorf("output mismatch error: wanted [{2 example.com}] ; got %v", res.Entries)
+		}
+
+		report := res.Skipped
+		if report.Count != 3 {
+			t.Errorf("skip count mismatch: wanted %d ; got %d", 3, report.Count)
+		}
+		if report.Reasons[ErrInvalidDomain] != 2 || report.Reasons[csv.ErrFieldCount] != 1 {
+			t.Errorf("unexpected skip reasons: %v", report.Reasons)
+		}
+		if len(report.Errors) != 0 {
+			t.Errorf("expected no collected errors; got %v", report.Errors)
+		}
+	})
+
+	t.Run("SkipAndCollect", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(invalidRowsInput),
+			WithErrorPolicy(SkipAndCollect),
+			WithMaxErrors(2),
+		)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		report := res.Skipped
+
+		if report.Count != 3 {
+			t.Errorf("skip count mismatch: wanted %d ; got %d", 3, report.Count)
+		}
+		if len(report.Errors) != 2 {
+			t.Errorf("collected errors mismatch: wanted %d ; got %d", 2, len(report.Errors))
+			return
+		}
+		for idx, wants := range []struct {
+			line int
+			err  error
+		}{
+			{line: 3, err: ErrInvalidDomain},
+			{line: 4, err: csv.ErrFieldCount},
+		} {
+			if report.Errors[idx].Line != wants.line || !errors.Is(report.Errors[idx], wants.err) {
+				t.Errorf("unexpected row error #%d: wanted line %d with %v ; got %v", idx, wants.line, wants.err, report.Errors[idx])
+			}
+		}
+	})
+}
@@ -0,0 +1,390 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+var errInvalidDelim = errors.New("csv: invalid field or comment delimiter")
+
+// scanner is a byte-level CSV record reader following RFC 4180, as a drop-in for a
+// csv.Reader with ReuseRecord set: it supports the same options and reports the same
+// errors (including their lines and byte columns), but it does not allocate per record.
+//
+// The returned fields are views into the scanner's own buffer, and are only valid until
+// the next call to Read; callers must copy any field they retain. Once a column is selected
+// (see selectColumn), the remaining fields are still split, but returned empty
+type scanner struct {
+	// r is the buffered input, unless reading from data
+	r *bufio.Reader
+	// data is the whole input, when it is already in memory (such as a memory-mapped file),
+	// where off is the offset of the next line
+	data []byte
+	off  int
+
+	comma            rune
+	comment          rune
+	lazyQuotes       bool
+	trimLeadingSpace bool
+	fieldsPerRecord  int
+	// column is the only field to unescape and return, or -1 for all fields
+	column int
+
+	// numLine is the current line number in the input
+	numLine int
+	// recordLine is the line number where the last record starts
+	recordLine int
+
+	// raw holds lines longer than the bufio.Reader's buffer
+	raw []byte
+	// buf holds the unescaped (selected) fields in the last record, back to back
+	buf []byte
+	// ends holds the end offset in buf for each field in the last record
+	ends   []int
+	fields []string
+}
+
+// columnSelector is implemented by record readers that can skip decoding all fields in a
+// record except for one
+type columnSelector interface {
+	selectColumn(idx int)
+}
+
+func newScanner(r io.Reader, comma, comment rune) (*scanner, error) {
+	if !validDelim(comma) || (comment != 0 && !validDelim(comment)) || comma == comment {
+		return nil, errInvalidDelim
+	}
+
+	s := &scanner{
+		comma:   comma,
+		comment: comment,
+		column:  -1,
+	}
+
+	if r != nil {
+		br, ok := r.(*bufio.Reader)
+		if !ok {
+			br = bufio.NewReader(r)
+		}
+		s.r = br
+	}
+
+	return s, nil
+}
+
+// newBytesScanner creates a scanner reading the lines in `data` directly, without copying them
+// into a buffer. The data is never modified, so it may be a read-only memory mapping
+func newBytesScanner(data []byte, comma, comment rune) (*scanner, error) {
+	s, err := newScanner(nil, comma, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	s.resetBytes(data, 0)
+	return s, nil
+}
+
+func validDelim(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// fork returns an idle scanner with the same settings as `s`, including the selected column and
+// the number of fields per record (once set from the first record); see resetBytes
+func (s *scanner) fork() *scanner {
+	return &scanner{
+		comma:            s.comma,
+		comment:          s.comment,
+		lazyQuotes:       s.lazyQuotes,
+		trimLeadingSpace: s.trimLeadingSpace,
+		fieldsPerRecord:  s.fieldsPerRecord,
+		column:           s.column,
+	}
+}
+
+// resetBytes makes the scanner read the lines in `data` directly, where the first line follows
+// line number `line`
+func (s *scanner) resetBytes(data []byte, line int) {
+	s.r = nil
+	s.data = data
+	s.off = 0
+	s.numLine = line
+}
+
+func (s *scanner) selectColumn(idx int) {
+	s.column = idx
+}
+
+// FieldPos returns the line of the last record read, for all fields; the column is not tracked
+func (s *scanner) FieldPos(int) (line, column int) {
+	return s.recordLine, 1
+}
+
+// Read returns the next record in the input, or io.EOF once exhausted. On a csv.ParseError,
+// the fields read so far are returned along with the error
+func (s *scanner) Read() ([]string, error) {
+	line, errRead := s.skipLines()
+	if errRead == io.EOF {
+		return nil, errRead
+	}
+
+	s.recordLine = s.numLine
+	s.buf = s.buf[:0]
+	s.ends = s.ends[:0]
+
+	var (
+		err      error
+		commaLen = utf8.RuneLen(s.comma)
+		// col is the 1-based byte column in the current line, for error reporting
+		col = 1
+	)
+
+parseField:
+	for {
+		keep := s.column < 0 || len(s.ends) == s.column
+
+		if s.trimLeadingSpace {
+			idx := bytes.IndexFunc(line, func(r rune) bool { return !unicode.IsSpace(r) })
+			if idx < 0 {
+				idx = len(line)
+				col -= lengthNL(line)
+			}
+			line = line[idx:]
+			col += idx
+		}
+
+		if len(line) == 0 || line[0] != '"' {
+			// unquoted field, up to the next delimiter or the end of the line
+			idx := indexRune(line, s.comma)
+			field := line
+			if idx >= 0 {
+				field = field[:idx]
+			} else {
+				field = field[:len(field)-lengthNL(field)]
+			}
+
+			if !s.lazyQuotes {
+				if j := bytes.IndexByte(field, '"'); j >= 0 {
+					err = s.parseError(col+j, csv.ErrBareQuote)
+					break parseField
+				}
+			}
+			if keep {
+				s.buf = append(s.buf, field...)
+			}
+			s.ends = append(s.ends, len(s.buf))
+
+			if idx < 0 {
+				break parseField
+			}
+			line = line[idx+commaLen:]
+			col += idx + commaLen
+			continue parseField
+		}
+
+		// quoted field, which may span several lines
+		line = line[1:]
+		col++
+
+		for {
+			idx := bytes.IndexByte(line, '"')
+			switch {
+			case idx >= 0:
+				if keep {
+					s.buf = append(s.buf, line[:idx]...)
+				}
+				line = line[idx+1:]
+				col += idx + 1
+
+				switch r, _ := utf8.DecodeRune(line); {
+				case r == '"':
+					// escaped quote
+					if keep {
+						s.buf = append(s.buf, '"')
+					}
+					line = line[1:]
+					col++
+				case r == s.comma:
+					s.ends = append(s.ends, len(s.buf))
+					line = line[commaLen:]
+					col += commaLen
+					continue parseField
+				case lengthNL(line) == len(line):
+					s.ends = append(s.ends, len(s.buf))
+					break parseField
+				case s.lazyQuotes:
+					if keep {
+						s.buf = append(s.buf, '"')
+					}
+				default:
+					// the column of the quote ending the field
+					err = s.parseError(col-1, csv.ErrQuote)
+					break parseField
+				}
+			case len(line) > 0:
+				// the field continues in the next line
+				if keep {
+					s.buf = append(s.buf, line...)
+				}
+				if errRead != nil {
+					break parseField
+				}
+				col += len(line)
+				line, errRead = s.readLine()
+				if len(line) > 0 {
+					col = 1
+				}
+				if errRead == io.EOF {
+					errRead = nil
+				}
+			default:
+				// abrupt end of input
+				if !s.lazyQuotes && errRead == nil {
+					err = s.parseError(col, csv.ErrQuote)
+					break parseField
+				}
+				s.ends = append(s.ends, len(s.buf))
+				break parseField
+			}
+		}
+	}
+
+	if err == nil {
+		err = errRead
+	}
+
+	s.fields = s.fields[:0]
+	str := bytesToString(s.buf)
+	for idx, end := range s.ends {
+		start := 0
+		if idx > 0 {
+			start = s.ends[idx-1]
+		}
+		s.fields = append(s.fields, str[start:end])
+	}
+
+	switch {
+	case s.fieldsPerRecord > 0:
+		if len(s.fields) != s.fieldsPerRecord && err == nil {
+			err = &csv.ParseError{StartLine: s.recordLine, Line: s.recordLine, Column: 1, Err: csv.ErrFieldCount}
+		}
+	case s.fieldsPerRecord == 0:
+		s.fieldsPerRecord = len(s.fields)
+	}
+
+	return s.fields, err
+}
+
+func (s *scanner) parseError(col int, err error) error {
+	return &csv.ParseError{StartLine: s.recordLine, Line: s.numLine, Column: col, Err: err}
+}
+
+// skipLines reads lines until one that is neither empty nor a comment
+func (s *scanner) skipLines() ([]byte, error) {
+	for {
+		line, err := s.readLine()
+		if s.comment != 0 && hasRunePrefix(line, s.comment) {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err == nil && len(line) == lengthNL(line) {
+			continue
+		}
+
+		return line, err
+	}
+}
+
+// readLine returns the next line in the input, with a CRLF ending normalized to LF. The
+// returned slice is only valid until the next call. A final line without a line ending is
+// returned with a nil error; io.EOF is only returned along with an empty line
+func (s *scanner) readLine() (line []byte, err error) {
+	switch {
+	case s.r == nil:
+		line, err = s.sliceLine()
+	default:
+		line, err = s.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			s.raw = append(s.raw[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = s.r.ReadSlice('\n')
+				s.raw = append(s.raw, line...)
+			}
+			line = s.raw
+		}
+	}
+
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+		// a trailing carriage return at the end of the input is dropped
+		if line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+	}
+	if len(line) > 0 {
+		s.numLine++
+	}
+
+	if n := len(line); n >= 2 && line[n-2] == '\r' && line[n-1] == '\n' {
+		// the line may not be writable (see newBytesScanner), so it is copied
+		s.raw = append(append(s.raw[:0], line[:n-2]...), '\n')
+		line = s.raw
+	}
+
+	return line, err
+}
+
+// sliceLine returns the next line in data, without copying it
+func (s *scanner) sliceLine() ([]byte, error) {
+	rest := s.data[s.off:]
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if idx := bytes.IndexByte(rest, '\n'); idx >= 0 {
+		s.off += idx + 1
+		return rest[:idx+1], nil
+	}
+
+	s.off = len(s.data)
+	return rest, io.EOF
+}
+
+// lengthNL returns 1 if `b` ends with a line feed, and 0 otherwise
+func lengthNL(b []byte) int {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
+
+func hasRunePrefix(b []byte, r rune) bool {
+	if r < utf8.RuneSelf {
+		return len(b) > 0 && b[0] == byte(r)
+	}
+	first, _ := utf8.DecodeRune(b)
+	return first == r
+}
+
+func indexRune(b []byte, r rune) int {
+	if r < utf8.RuneSelf {
+		return bytes.IndexByte(b, byte(r))
+	}
+	return bytes.IndexRune(b, r)
+}
+
+// bytesToString returns a string sharing the memory of `b`, which must not be modified
+// while the string is in use
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
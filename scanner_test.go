@@ -0,0 +1,264 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	t.Run("MatchesEncodingCSV", func(t *testing.T) {
+		type settings struct {
+			comma            rune
+			comment          rune
+			lazyQuotes       bool
+			trimLeadingSpace bool
+			fieldsPerRecord  int
+		}
+
+		for _, testcase := range []struct {
+			name  string
+			input string
+			cfg   settings
+		}{
+			{name: "Simple", input: "a,b,c\n1,2,3\n"},
+			{name: "NoTrailingNewline", input: "a,b,c\n1,2,3"},
+			{name: "CRLF", input: "a,b,c\r\n1,2,3\r\n"},
+			{name: "TrailingCR", input: "a,b,c\r\n1,2,3\r"},
+			{name: "BareCR", input: "a,b\rc,d\n"},
+			{name: "EmptyLines", input: "\na,b\n\n\r\n1,2\n\n"},
+			{name: "EmptyFields", input: ",,\n,x,\n"},
+			{name: "Quoted", input: "\"a\",\"b,c\",\"d\"\"e\"\n"},
+			{name: "QuotedCRLF", input: "\"a\r\nb\",c\r\n\"\",\"\"\"\"\r\n"},
+			{name: "MultilineQuoted", input: "\"john\n\ndoe\",john@example.com\njane,jane@example.com\n"},
+			{name: "QuotedAtEOF", input: "a,\"b\""},
+			{name: "BareQuote", input: "a,b\"c,d\n"},
+			{name: "BareQuoteLazy", input: "a,b\"c,d\n", cfg: settings{lazyQuotes: true}},
+			{name: "ExtraneousQuote", input: "\"a\"b,c\n"},
+			{name: "ExtraneousQuoteLazy", input: "\"a\"b,c\n", cfg: settings{lazyQuotes: true}},
+			{name: "UnterminatedQuote", input: "a,\"b\nc\n"},
+			{name: "UnterminatedQuoteLazy", input: "a,\"b\nc\n", cfg: settings{lazyQuotes: true}},
+			{name: "UnterminatedMultilineQuote", input: "a,\"b\ncd"},
+			{name: "ExtraneousMultilineQuote", input: "a,\"b\ncd\"e,f\n"},
+			{name: "BareQuoteAfterMultiline", input: "a,\"b\nc\",d\"e\n"},
+			{name: "TrimmedSpacesBeforeEOF", input: "a,\"b\n  ", cfg: settings{trimLeadingSpace: true}},
+			{name: "FieldCount", input: "a,b\n1,2,3\n4,5\n"},
+			{name: "FieldCountFixed", input: "a,b\n1,2\n", cfg: settings{fieldsPerRecord: 3}},
+			{name: "VariableFields", input: "a,b\n1,2,3\n4\n", cfg: settings{fieldsPerRecord: -1}},
+			{name: "Semicolon", input: "a;b\n\"1;2\";3\n", cfg: settings{comma: ';'}},
+			{name: "MultiByteComma", input: "a€b\n\"1€2\"€3\n", cfg: settings{comma: '€'}},
+			{name: "Comment", input: "#x\na,b\n# y,z\n1,2\n#", cfg: settings{comment: '#'}},
+			{name: "TrimLeadingSpace", input: " a,  \"b\"\n\t1, 2 \n   \n", cfg: settings{trimLeadingSpace: true}},
+			{name: "LongLine", input: "a," + strings.Repeat("x", 10000) + ",\"" + strings.Repeat("y\"\"", 3000) + "\"\n1,2,3\n"},
+		} {
+			t.Run(testcase.name, func(t *testing.T) {
+				comma := testcase.cfg.comma
+				if comma == 0 {
+					comma = ','
+				}
+
+				cr := csv.NewReader(strings.NewReader(testcase.input))
+				cr.Comma = comma
+				cr.Comment = testcase.cfg.comment
+				cr.LazyQuotes = testcase.cfg.lazyQuotes
+				cr.TrimLeadingSpace = testcase.cfg.trimLeadingSpace
+				cr.FieldsPerRecord = testcase.cfg.fieldsPerRecord
+
+				sc, err := newScanner(strings.NewReader(testcase.input), comma, testcase.cfg.comment)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				sc.lazyQuotes = testcase.cfg.lazyQuotes
+				sc.trimLeadingSpace = testcase.cfg.trimLeadingSpace
+				sc.fieldsPerRecord = testcase.cfg.fieldsPerRecord
+
+				for idx := 0; ; idx++ {
+					wanted, wantedErr := cr.Read()
+					record, err := sc.Read()
+
+					if !reflect.DeepEqual(wanted, record) {
+						t.Errorf("output mismatch error on record %d: wanted %q ; got %q", idx, wanted, record)
+					}
+
+					var wantedParseErr, parseErr *csv.ParseError
+					switch {
+					case errors.As(wantedErr, &wantedParseErr):
+						if !errors.As(err, &parseErr) || *parseErr != *wantedParseErr {
+							t.Errorf("unexpected error on record %d: wanted %v ; got %v", idx, wantedErr, err)
+						}
+					case wantedErr != err:
+						t.Errorf("unexpected error on record %d: wanted %v ; got %v", idx, wantedErr, err)
+					}
+
+					if wantedErr == nil {
+						wantedLine, _ := cr.FieldPos(0)
+						if line, _ := sc.FieldPos(0); line != wantedLine {
+							t.Errorf("line mismatch error on record %d: wanted %d ; got %d", idx, wantedLine, line)
+						}
+					}
+
+					if wantedErr == io.EOF || err == io.EOF || t.Failed() {
+						return
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("SelectColumn", func(t *testing.T) {
+		sc, err := newScanner(strings.NewReader("\"a,1\",\"b\"\"2\",c\r\n\"x\ny\",\"z@example.com\",w\r\n"), ',', 0)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		sc.selectColumn(1)
+
+		for _, wanted := range [][]string{
+			{"", "b\"2", ""},
+			{"", "z@example.com", ""},
+		} {
+			record, err := sc.Read()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(wanted, record) {
+				t.Errorf("output mismatch error: wanted %q ; got %q", wanted, record)
+			}
+		}
+	})
+
+	t.Run("InvalidDelimiter", func(t *testing.T) {
+		for _, delim := range [][2]rune{{'"', 0}, {'\n', 0}, {0, 0}, {',', ','}, {',', '\r'}} {
+			if _, err := newScanner(strings.NewReader(""), delim[0], delim[1]); !errors.Is(err, errInvalidDelim) {
+				t.Errorf("unexpected error for %q: wanted %v ; got %v", delim, errInvalidDelim, err)
+			}
+		}
+	})
+
+	t.Run("ConstantAllocations", func(t *testing.T) {
+		allocs := func(data []byte) float64 {
+			return testing.AllocsPerRun(10, func() {
+				if _, err := ParseReader(bytes.NewReader(data)); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			})
+		}
+
+		// the same domains over ten times as many rows
+		once := allocs(rawData)
+		repeated := allocs(bytes.Repeat(rawData, 10))
+
+		if repeated > once*1.1 {
+			t.Errorf("allocations grow with the number of rows: %v for one copy ; %v for ten copies", once, repeated)
+		}
+	})
+}
+
+func TestSplitter(t *testing.T) {
+	// records returns the records in `data` (and their errors), read with the settings in `sc`
+	records := func(sc *scanner, data []byte) []string {
+		r := sc.fork()
+		r.resetBytes(data, 0)
+
+		var out []string
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				return out
+			}
+
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				out = append(out, parseErr.Err.Error())
+				continue
+			}
+			// fields are only valid until the next read
+			out = append(out, strings.Clone(strings.Join(record, "|")))
+		}
+	}
+
+	for _, testcase := range []struct {
+		name             string
+		input            string
+		comment          rune
+		lazyQuotes       bool
+		trimLeadingSpace bool
+	}{
+		{name: "Simple", input: "a,b\n1,2\n\n3,4\n5,6"},
+		{name: "CRLF", input: "a,b\r\n\"1\"\r\n\"2\r\n3\",4\r\n\r\n5,\"6\"\r\n"},
+		{name: "MultilineQuoted", input: "\"a\nb\",c\n\"\"\"\n\"\"\n\",d\n\"e,\n\nf\"\n"},
+		{name: "BareQuote", input: "a,b\"c,\"d\ne\",\"f\ng\n"},
+		{name: "BareQuoteLazy", input: "a,b\"c,\"d\ne\",\"f\ng\"\n", lazyQuotes: true},
+		{name: "ExtraneousQuote", input: "\"a\"b,\"c\nd\"\ne\n\"f\"\rg\"\nh\n"},
+		{name: "ExtraneousQuoteLazy", input: "\"a\"b,\"c\nd\"\ne\n\"f\"\rg\"\nh\n", lazyQuotes: true},
+		{name: "Comment", input: "#\"a\nb,c\n#d\n\"#e\n#f\",g\n", comment: '#'},
+		{name: "TrimLeadingSpace", input: " \"a\nb\", \t\"c\nd\"\n\u00a0\"e\nf\",\u3000\"g\nh\"\n", trimLeadingSpace: true},
+		{name: "LeadingSpace", input: " \"a\nb\", \t\"c\nd\"\n\u00a0\"e\nf\",\u3000\"g\nh\"\n", lazyQuotes: true},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			sc, err := newScanner(strings.NewReader(""), ',', testcase.comment)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			sc.lazyQuotes = testcase.lazyQuotes
+			sc.trimLeadingSpace = testcase.trimLeadingSpace
+			sc.fieldsPerRecord = -1
+
+			sp := splitter{
+				comma:            ',',
+				comment:          byte(testcase.comment),
+				lazyQuotes:       testcase.lazyQuotes,
+				trimLeadingSpace: testcase.trimLeadingSpace,
+			}
+
+			data := []byte(testcase.input)
+			wanted := records(sc, data)
+
+			// feeds the data one byte at a time, to split multi-byte characters
+			var ends []int
+			scanned := 0
+			for idx := 1; idx <= len(data); idx++ {
+				n, end := sp.scan(data[scanned:idx])
+				if end >= 0 {
+					ends = append(ends, scanned+end)
+				}
+				scanned += n
+			}
+
+			if len(ends) == 0 {
+				t.Errorf("expected the data to be split")
+			}
+
+			for _, end := range ends {
+				split := append(records(sc, data[:end]), records(sc, data[end:])...)
+				if !reflect.DeepEqual(wanted, split) {
+					t.Errorf("output mismatch error when splitting at %d: wanted %q ; got %q", end, wanted, split)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sc, _ := newScanner(bytes.NewReader(rawData), ',', 0)
+		sc.selectColumn(2)
+
+		for {
+			if _, err := sc.Read(); err != nil {
+				if err != io.EOF {
+					b.Error(err)
+				}
+				break
+			}
+		}
+	}
+}
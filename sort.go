@@ -0,0 +1,142 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidSortOrder = errors.New("invalid sort order")
+
+// SortOrder defines how the entries are sorted
+type SortOrder uint8
+
+const (
+	// SortByDomain sorts entries alphabetically by domain name. This is the default
+	SortByDomain SortOrder = iota
+	// SortByCountDesc sorts entries by count, highest first; ties are sorted by domain name
+	SortByCountDesc
+	// SortByCountAsc sorts entries by count, lowest first; ties are sorted by domain name
+	SortByCountAsc
+	// SortByReverseLabel sorts entries alphabetically by their labels from right to left, so
+	// that subdomains (`mail.google.com`) follow their parent domain (`google.com`)
+	SortByReverseLabel
+	// SortByTLD groups entries by top-level domain, sorted alphabetically; within each
+	// group, entries are sorted by count (highest first) and then by domain name
+	SortByTLD
+)
+
+var sortOrderNames = map[SortOrder]string{
+	SortByDomain:       "domain",
+	SortByCountDesc:    "count-desc",
+	SortByCountAsc:     "count-asc",
+	SortByReverseLabel: "reverse-label",
+	SortByTLD:          "tld",
+}
+
+var sortOrderAliases = map[string]SortOrder{
+	"alpha": SortByDomain,
+	"count": SortByCountDesc,
+	"label": SortByReverseLabel,
+}
+
+// String implements the fmt.Stringer interface
+func (o SortOrder) String() string {
+	if name, ok := sortOrderNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("SortOrder(%d)", o)
+}
+
+// ParseSortOrder returns the SortOrder for `name`, one of `domain` (or `alpha`), `count-desc`
+// (or `count`), `count-asc`, `reverse-label` (or `label`) and `tld`
+func ParseSortOrder(name string) (SortOrder, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for order, n := range sortOrderNames {
+		if n == name {
+			return order, nil
+		}
+	}
+	if order, ok := sortOrderAliases[name]; ok {
+		return order, nil
+	}
+
+	return SortByDomain, fmt.Errorf("%w: %q", ErrInvalidSortOrder, name)
+}
+
+// SortEntries sorts `entries` in place, according to `order`
+func SortEntries(entries []Entry, order SortOrder) {
+	var less func(i, j int) bool
+
+	switch order {
+	case SortByCountDesc:
+		less = func(i, j int) bool {
+			if entries[i].Count != entries[j].Count {
+				return entries[i].Count > entries[j].Count
+			}
+			return entries[i].Domain < entries[j].Domain
+		}
+	case SortByCountAsc:
+		less = func(i, j int) bool {
+			if entries[i].Count != entries[j].Count {
+				return entries[i].Count < entries[j].Count
+			}
+			return entries[i].Domain < entries[j].Domain
+		}
+	case SortByReverseLabel:
+		less = func(i, j int) bool {
+			return compareReverseLabels(entries[i].Domain, entries[j].Domain) < 0
+		}
+	case SortByTLD:
+		less = func(i, j int) bool {
+			if c := strings.Compare(tld(entries[i].Domain), tld(entries[j].Domain)); c != 0 {
+				return c < 0
+			}
+			if entries[i].Count != entries[j].Count {
+				return entries[i].Count > entries[j].Count
+			}
+			return entries[i].Domain < entries[j].Domain
+		}
+	default:
+		less = func(i, j int) bool {
+			switch strings.Compare(entries[i].Domain, entries[j].Domain) {
+			case -1:
+				return true
+			default:
+				return false
+			}
+		}
+	}
+
+	sort.Slice(entries, less)
+}
+
+// tld returns the last label in `domain`
+func tld(domain string) string {
+	return domain[strings.LastIndexByte(domain, '.')+1:]
+}
+
+// compareReverseLabels compares domains `a` and `b` label by label, from right to left,
+// without allocating. A domain sorts before its own subdomains
+func compareReverseLabels(a, b string) int {
+	for {
+		ia, ib := strings.LastIndexByte(a, '.'), strings.LastIndexByte(b, '.')
+
+		if c := strings.Compare(a[ia+1:], b[ib+1:]); c != 0 {
+			return c
+		}
+
+		switch {
+		case ia < 0 && ib < 0:
+			return 0
+		case ia < 0:
+			return -1
+		case ib < 0:
+			return 1
+		}
+
+		a, b = a[:ia], b[:ib]
+	}
+}
@@ -0,0 +1,99 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestSortEntries(t *testing.T) {
+	input := []Entry{
+		{Count: 3, Domain: "mail.google.com"},
+		{Count: 5, Domain: "google.com"},
+		{Count: 5, Domain: "bbc.co.uk"},
+		{Count: 1, Domain: "example.org"},
+		{Count: 2, Domain: "google.co.uk"},
+		{Count: 5, Domain: "apple.com"},
+	}
+
+	for _, testcase := range []struct {
+		order SortOrder
+		wants []string
+	}{
+		{
+			order: SortByDomain,
+			wants: []string{"apple.com", "bbc.co.uk", "example.org", "google.co.uk", "google.com", "mail.google.com"},
+		},
+		{
+			order: SortByCountDesc,
+			wants: []string{"apple.com", "bbc.co.uk", "google.com", "mail.google.com", "google.co.uk", "example.org"},
+		},
+		{
+			order: SortByCountAsc,
+			wants: []string{"example.org", "google.co.uk", "mail.google.com", "apple.com", "bbc.co.uk", "google.com"},
+		},
+		{
+			order: SortByReverseLabel,
+			wants: []string{"apple.com", "google.com", "mail.google.com", "example.org", "bbc.co.uk", "google.co.uk"},
+		},
+		{
+			order: SortByTLD,
+			wants: []string{"apple.com", "google.com", "mail.google.com", "example.org", "bbc.co.uk", "google.co.uk"},
+		},
+	} {
+		t.Run(testcase.order.String(), func(t *testing.T) {
+			entries := make([]Entry, len(input))
+			copy(entries, input)
+
+			SortEntries(entries, testcase.order)
+
+			domains := make([]string, len(entries))
+			for idx, e := range entries {
+				domains[idx] = e.Domain
+			}
+			if strings.Join(domains, " ") != strings.Join(testcase.wants, " ") {
+				t.Errorf("output mismatch error: wanted %v ; got %v", testcase.wants, domains)
+			}
+		})
+	}
+
+	t.Run("WithSort", func(t *testing.T) {
+		entries, err := Parse(rawPath, WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if entries[0].Domain != "loc.gov" || entries[0].Count != 14 {
+			t.Errorf("output mismatch error: wanted {14 loc.gov} first ; got %v", entries[0])
+		}
+	})
+}
+
+func TestParseSortOrder(t *testing.T) {
+	for name, wants := range map[string]SortOrder{
+		"domain":        SortByDomain,
+		"alpha":         SortByDomain,
+		"count":         SortByCountDesc,
+		"Count-Desc":    SortByCountDesc,
+		"count-asc":     SortByCountAsc,
+		"reverse-label": SortByReverseLabel,
+		"label":         SortByReverseLabel,
+		" tld ":         SortByTLD,
+	} {
+		order, err := ParseSortOrder(name)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", name, err)
+			continue
+		}
+		if order != wants {
+			t.Errorf("sort order mismatch for %q: wanted %v ; got %v", name, wants, order)
+		}
+	}
+
+	if _, err := ParseSortOrder("random"); !errors.Is(err, ErrInvalidSortOrder) {
+		t.Errorf("unexpected error: wanted %v ; got %v", ErrInvalidSortOrder, err)
+	}
+}
@@ -0,0 +1,161 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed data/public_suffix_list.dat
+var embeddedSuffixList []byte
+
+var ErrPublicSuffix = errors.New("domain is a public suffix")
+
+var (
+	defaultSuffixList     *SuffixList
+	defaultSuffixListOnce sync.Once
+)
+
+// SuffixList holds the rules from a Public Suffix List (https://publicsuffix.org), used to
+// find the registrable domain (eTLD+1) for a domain name. It is safe for concurrent use
+type SuffixList struct {
+	rules      map[string]struct{}
+	wildcards  map[string]struct{}
+	exceptions map[string]struct{}
+}
+
+// DefaultSuffixList returns the SuffixList parsed from the Public Suffix List snapshot
+// embedded in the package
+func DefaultSuffixList() *SuffixList {
+	defaultSuffixListOnce.Do(func() {
+		list, err := LoadSuffixList(bytes.NewReader(embeddedSuffixList))
+		if err != nil {
+			panic(fmt.Sprintf("customerimporter: invalid embedded public suffix list: %v", err))
+		}
+		defaultSuffixList = list
+	})
+
+	return defaultSuffixList
+}
+
+// LoadSuffixList parses a SuffixList from `r`, in the format of the Public Suffix List
+// (https://publicsuffix.org/list/public_suffix_list.dat): one rule per line, with `//`
+// comments, `*.` wildcard rules and `!` exception rules. Rules in Unicode are converted
+// to their ASCII form
+func LoadSuffixList(r io.Reader) (*SuffixList, error) {
+	list := &SuffixList{
+		rules:      map[string]struct{}{},
+		wildcards:  map[string]struct{}{},
+		exceptions: map[string]struct{}{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		rule := scanner.Text()
+		if idx := strings.IndexAny(rule, " \t"); idx >= 0 {
+			rule = rule[:idx]
+		}
+		if rule == "" || strings.HasPrefix(rule, "//") {
+			continue
+		}
+
+		target := list.rules
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			target, rule = list.exceptions, rule[1:]
+		case strings.HasPrefix(rule, "*."):
+			target, rule = list.wildcards, rule[2:]
+		}
+
+		ascii, err := ToASCII(rule)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q: %w", line, rule, err)
+		}
+		target[ascii] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Len returns the number of rules in the SuffixList
+func (l *SuffixList) Len() int {
+	return len(l.rules) + len(l.wildcards) + len(l.exceptions)
+}
+
+// PublicSuffix returns the public suffix (eTLD) of `domain`, such as `co.uk` for
+// `bbc.co.uk`. When no rule matches, the top-level domain is returned. Domains in Unicode
+// are matched in their ASCII form, but returned in their original form
+func (l *SuffixList) PublicSuffix(domain string) string {
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		ascii = strings.ToLower(domain)
+	}
+
+	return suffixLabels(domain, l.publicSuffixLabels(ascii))
+}
+
+// RegistrableDomain returns the registrable domain (eTLD+1) of `domain`, such as `bbc.co.uk`
+// for `news.bbc.co.uk`. Returns ErrPublicSuffix if `domain` is itself a public suffix
+func (l *SuffixList) RegistrableDomain(domain string) (string, error) {
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		ascii = strings.ToLower(domain)
+	}
+
+	n := l.publicSuffixLabels(ascii)
+	if n >= strings.Count(ascii, ".")+1 {
+		return "", fmt.Errorf("%w: %q", ErrPublicSuffix, domain)
+	}
+
+	return suffixLabels(domain, n+1), nil
+}
+
+// publicSuffixLabels returns the number of labels in the public suffix of the ASCII `domain`
+func (l *SuffixList) publicSuffixLabels(domain string) int {
+	total := strings.Count(domain, ".") + 1
+
+	// walk from the longest suffix (the whole domain) to the shortest (the TLD), so that the
+	// first matching rule is the longest one
+	suffix := domain
+	for n := total; n > 0; n-- {
+		if _, ok := l.exceptions[suffix]; ok {
+			return n - 1
+		}
+		if _, ok := l.rules[suffix]; ok {
+			return n
+		}
+
+		parent := suffix[strings.IndexByte(suffix, '.')+1:]
+		if n > 1 {
+			if _, ok := l.wildcards[parent]; ok {
+				return n
+			}
+		}
+
+		suffix = parent
+	}
+
+	// the implicit `*` rule
+	return 1
+}
+
+// suffixLabels returns the last `n` labels of `domain`
+func suffixLabels(domain string, n int) string {
+	idx := len(domain)
+	for ; n > 0; n-- {
+		idx = strings.LastIndexByte(domain[:idx], '.')
+		if idx < 0 {
+			return domain
+		}
+	}
+	return domain[idx+1:]
+}
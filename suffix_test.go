@@ -0,0 +1,128 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestSuffixList(t *testing.T) {
+	list := DefaultSuffixList()
+
+	for _, testcase := range []struct {
+		domain      string
+		suffix      string
+		registrable string
+		err         error
+	}{
+		{domain: "company.com", suffix: "com", registrable: "company.com"},
+		{domain: "mail.company.com", suffix: "com", registrable: "company.com"},
+		{domain: "bbc.co.uk", suffix: "co.uk", registrable: "bbc.co.uk"},
+		{domain: "news.bbc.co.uk", suffix: "co.uk", registrable: "bbc.co.uk"},
+		{domain: "123-reg.co.uk", suffix: "co.uk", registrable: "123-reg.co.uk"},
+		{domain: "miibeian.gov.cn", suffix: "gov.cn", registrable: "miibeian.gov.cn"},
+		{domain: "vic.gov.au", suffix: "vic.gov.au", err: ErrPublicSuffix},
+		{domain: "health.vic.gov.au", suffix: "vic.gov.au", registrable: "health.vic.gov.au"},
+		{domain: "a.com.tr", suffix: "com.tr", registrable: "a.com.tr"},
+		{domain: "shop.example.com.br", suffix: "com.br", registrable: "example.com.br"},
+		{domain: "co.uk", suffix: "co.uk", err: ErrPublicSuffix},
+		{domain: "user.github.io", suffix: "github.io", registrable: "user.github.io"},
+		{domain: "example.unknowntld", suffix: "unknowntld", registrable: "example.unknowntld"},
+		// wildcard and exception rules
+		{domain: "www.example.foo.ck", suffix: "foo.ck", registrable: "example.foo.ck"},
+		{domain: "www.ck", suffix: "ck", registrable: "www.ck"},
+		{domain: "a.city.kawasaki.jp", suffix: "kawasaki.jp", registrable: "city.kawasaki.jp"},
+		{domain: "a.b.kawasaki.jp", suffix: "b.kawasaki.jp", registrable: "a.b.kawasaki.jp"},
+		// Unicode rules and domains
+		{domain: "mail.пример.рф", suffix: "рф", registrable: "пример.рф"},
+		{domain: "shop.example.公司.cn", suffix: "公司.cn", registrable: "example.公司.cn"},
+	} {
+		t.Run(testcase.domain, func(t *testing.T) {
+			if suffix := list.PublicSuffix(testcase.domain); suffix != testcase.suffix {
+				t.Errorf("public suffix mismatch: wanted %q ; got %q", testcase.suffix, suffix)
+			}
+
+			registrable, err := list.RegistrableDomain(testcase.domain)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if registrable != testcase.registrable {
+				t.Errorf("registrable domain mismatch: wanted %q ; got %q", testcase.registrable, registrable)
+			}
+		})
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		list, err := LoadSuffixList(strings.NewReader("// custom list\n\ncom\nco.example.com  extra\n*.wild.example\n!keep.wild.example\n"))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if list.Len() != 4 {
+			t.Errorf("rule count mismatch: wanted %d ; got %d", 4, list.Len())
+		}
+		for domain, wants := range map[string]string{
+			"a.b.co.example.com":     "b.co.example.com",
+			"a.b.example.com":        "example.com",
+			"a.b.wild.example":       "a.b.wild.example",
+			"a.keep.wild.example":    "keep.wild.example",
+			"deep.a.keep.wild.other": "wild.other",
+		} {
+			if registrable, _ := list.RegistrableDomain(domain); registrable != wants {
+				t.Errorf("registrable domain mismatch for %q: wanted %q ; got %q", domain, wants, registrable)
+			}
+		}
+	})
+}
+
+func TestRollup(t *testing.T) {
+	const input = `email
+a@company.com
+b@mail.company.com
+c@eu.company.com
+d@mail.company.com
+e@bbc.co.uk
+f@news.bbc.co.uk
+g@co.uk
+`
+
+	res, err := CountReader(strings.NewReader(input), WithRollup(nil), WithSort(SortByCountDesc))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if len(res.Entries) != 3 {
+		t.Errorf("output length mismatch error: wanted %d ; got %d: %v", 3, len(res.Entries), res.Entries)
+		return
+	}
+
+	company := res.Entries[0]
+	if company.Domain != "company.com" || company.Count != 4 || len(company.Children) != 3 {
+		t.Errorf("output mismatch error: wanted company.com with 4 users in 3 domains ; got %v", company)
+		return
+	}
+	if child := company.Children[0]; child.Domain != "mail.company.com" || child.Count != 2 {
+		t.Errorf("output mismatch error: wanted {2 mail.company.com} ; got %v", child)
+	}
+
+	if bbc, ok := res.Lookup("bbc.co.uk"); !ok || bbc.Count != 2 || len(bbc.Children) != 2 {
+		t.Errorf("output mismatch error: wanted bbc.co.uk with 2 users in 2 domains ; got %v", bbc)
+	}
+	if suffix, ok := res.Lookup("co.uk"); !ok || suffix.Count != 1 {
+		t.Errorf("output mismatch error: wanted {1 co.uk} ; got %v", suffix)
+	}
+	if records := res.Records(); len(records[0].Children) != 3 {
+		t.Errorf("expected the rolled-up records to include their children; got %v", records[0])
+	}
+}
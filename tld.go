@@ -0,0 +1,271 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// embeddedTLDs lists the TLDs in the root zone as of 2023-02-09, with their IANA kind and, for
+// country-code TLDs, their country
+//
+//go:embed data/tlds.csv
+var embeddedTLDs []byte
+
+var (
+	tldTable     map[string]TLDInfo
+	tldTableOnce sync.Once
+)
+
+// TLDKind classifies top-level domains, following the IANA Root Zone Database
+type TLDKind uint8
+
+const (
+	// TLDUnknown is a top-level domain that is not in the embedded table
+	TLDUnknown TLDKind = iota
+	// TLDGeneric is a generic top-level domain, such as `com` or `org`
+	TLDGeneric
+	// TLDGenericRestricted is a generic top-level domain with eligibility rules, such as `biz`
+	TLDGenericRestricted
+	// TLDSponsored is a top-level domain run by a sponsoring organization, such as `edu` or `gov`
+	TLDSponsored
+	// TLDCountry is a country-code top-level domain, such as `uk` or `de`
+	TLDCountry
+	// TLDInfrastructure is the `arpa` top-level domain
+	TLDInfrastructure
+)
+
+var tldKindNames = map[TLDKind]string{
+	TLDUnknown:           "unknown",
+	TLDGeneric:           "generic",
+	TLDGenericRestricted: "generic-restricted",
+	TLDSponsored:         "sponsored",
+	TLDCountry:           "country",
+	TLDInfrastructure:    "infrastructure",
+}
+
+// String implements the fmt.Stringer interface
+func (k TLDKind) String() string {
+	if name, ok := tldKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("TLDKind(%d)", k)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (k TLDKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// TLDInfo describes a top-level domain
+type TLDInfo struct {
+	TLD     string  `json:"tld"`
+	Kind    TLDKind `json:"kind"`
+	Country string  `json:"country,omitempty"`
+}
+
+// LookupTLD returns the TLDInfo for the top-level domain `tld` (such as `uk` or `xn--p1ai`),
+// from the table embedded in the package. Unicode TLDs (such as `рф`) are looked up in
+// their ASCII form. Unknown TLDs have the TLDUnknown kind
+func LookupTLD(tld string) TLDInfo {
+	tldTableOnce.Do(func() {
+		table, err := loadTLDTable(bytes.NewReader(embeddedTLDs))
+		if err != nil {
+			panic(fmt.Sprintf("customerimporter: invalid embedded TLD table: %v", err))
+		}
+		tldTable = table
+	})
+
+	if ascii, err := ToASCII(tld); err == nil {
+		tld = ascii
+	}
+
+	if info, ok := tldTable[tld]; ok {
+		return info
+	}
+	return TLDInfo{TLD: tld, Kind: TLDUnknown}
+}
+
+func loadTLDTable(r io.Reader) (map[string]TLDInfo, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = 3
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]TLDKind, len(tldKindNames))
+	for kind, name := range tldKindNames {
+		kinds[name] = kind
+	}
+
+	table := make(map[string]TLDInfo, len(records))
+	for _, record := range records[1:] {
+		kind, ok := kinds[record[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLD kind for %q: %q", record[0], record[1])
+		}
+
+		table[record[0]] = TLDInfo{
+			TLD:     record[0],
+			Kind:    kind,
+			Country: record[2],
+		}
+	}
+
+	return table, nil
+}
+
+// TLDEntry is the count of addresses under a top-level domain, in a TLDReport
+type TLDEntry struct {
+	TLDInfo
+	// Domains is the number of distinct domains under the TLD
+	Domains int `json:"domains"`
+	// Count is the number of addresses under the TLD
+	Count int `json:"count"`
+	// Share is the percentage of the total count under the TLD, rounded to 4 decimal places
+	Share float64 `json:"share"`
+}
+
+// CountryEntry is the count of addresses under a country's top-level domains, in a TLDReport
+type CountryEntry struct {
+	Country string `json:"country"`
+	// TLDs lists the country-code TLDs counted for the country, such as `gb` and `uk`
+	TLDs []string `json:"tlds"`
+	// Count is the number of addresses under the country's TLDs
+	Count int `json:"count"`
+	// Share is the percentage of the total count under the country's TLDs, rounded to 4 decimal places
+	Share float64 `json:"share"`
+}
+
+// TLDReport breaks down the domain counts by top-level domain, by country (for country-code
+// TLDs) and by TLDKind
+type TLDReport struct {
+	// Total is the number of addresses in the report
+	Total int `json:"total"`
+	// TLDs lists the counts per top-level domain, highest first
+	TLDs []TLDEntry `json:"tlds"`
+	// Countries lists the counts per country, highest first
+	Countries []CountryEntry `json:"countries"`
+	// Kinds maps each TLDKind to its count
+	Kinds map[TLDKind]int `json:"kinds"`
+}
+
+// NewTLDReport builds a TLDReport from the domain counts in `entries`. Rolled-up entries
+// (see WithRollup) are counted as a whole, as they share the same top-level domain
+func NewTLDReport(entries []Entry) *TLDReport {
+	var (
+		report    = &TLDReport{Kinds: map[TLDKind]int{}}
+		tlds      = map[string]*TLDEntry{}
+		countries = map[string]*CountryEntry{}
+	)
+
+	for _, e := range entries {
+		report.Total += e.Count
+
+		info := LookupTLD(strings.ToLower(tld(e.Domain)))
+		t, ok := tlds[info.TLD]
+		if !ok {
+			t = &TLDEntry{TLDInfo: info}
+			tlds[info.TLD] = t
+		}
+		t.Count += e.Count
+		t.Domains++
+		if len(e.Children) > 0 {
+			t.Domains += len(e.Children) - 1
+		}
+
+		report.Kinds[info.Kind] += e.Count
+	}
+
+	report.TLDs = make([]TLDEntry, 0, len(tlds))
+	for _, t := range tlds {
+		t.Share = percentage(t.Count, report.Total)
+		report.TLDs = append(report.TLDs, *t)
+
+		if t.Kind != TLDCountry {
+			continue
+		}
+		c, ok := countries[t.Country]
+		if !ok {
+			c = &CountryEntry{Country: t.Country}
+			countries[t.Country] = c
+		}
+		c.Count += t.Count
+		c.TLDs = append(c.TLDs, t.TLD)
+	}
+
+	report.Countries = make([]CountryEntry, 0, len(countries))
+	for _, c := range countries {
+		c.Share = percentage(c.Count, report.Total)
+		sort.Strings(c.TLDs)
+		report.Countries = append(report.Countries, *c)
+	}
+
+	sort.Slice(report.TLDs, func(i, j int) bool {
+		if report.TLDs[i].Count != report.TLDs[j].Count {
+			return report.TLDs[i].Count > report.TLDs[j].Count
+		}
+		return report.TLDs[i].TLD < report.TLDs[j].TLD
+	})
+	sort.Slice(report.Countries, func(i, j int) bool {
+		if report.Countries[i].Count != report.Countries[j].Count {
+			return report.Countries[i].Count > report.Countries[j].Count
+		}
+		return report.Countries[i].Country < report.Countries[j].Country
+	})
+
+	return report
+}
+
+// TLDReport builds a TLDReport from the Result's entries (see NewTLDReport)
+func (r *Result) TLDReport() *TLDReport {
+	return NewTLDReport(r.Entries)
+}
+
+// percentage returns `count` as a percentage of `total`, rounded to 4 decimal places
+func percentage(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)*1e6/float64(total)) / 1e4
+}
+
+// Encode writes the TLDReport's TLDs to `w` in the Format `f`
+func (r *TLDReport) Encode(w io.Writer, f Format) error {
+	bw := bufio.NewWriter(w)
+
+	if err := encodeRecords(bw, f, []string{"tld", "kind", "country", "domains", "count", "share"}, r.TLDs,
+		func(t TLDEntry) []string {
+			return []string{t.TLD, t.Kind.String(), t.Country, strconv.Itoa(t.Domains), strconv.Itoa(t.Count), formatShare(t.Share)}
+		},
+	); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// EncodeCountries writes the TLDReport's countries to `w` in the Format `f`
+func (r *TLDReport) EncodeCountries(w io.Writer, f Format) error {
+	bw := bufio.NewWriter(w)
+
+	if err := encodeRecords(bw, f, []string{"country", "tlds", "count", "share"}, r.Countries,
+		func(c CountryEntry) []string {
+			return []string{c.Country, strings.Join(c.TLDs, " "), strconv.Itoa(c.Count), formatShare(c.Share)}
+		},
+	); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,107 @@
+package customerimporter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestLookupTLD(t *testing.T) {
+	for tld, wants := range map[string]TLDInfo{
+		"com":      {TLD: "com", Kind: TLDGeneric},
+		"biz":      {TLD: "biz", Kind: TLDGenericRestricted},
+		"edu":      {TLD: "edu", Kind: TLDSponsored},
+		"arpa":     {TLD: "arpa", Kind: TLDInfrastructure},
+		"uk":       {TLD: "uk", Kind: TLDCountry, Country: "United Kingdom"},
+		"de":       {TLD: "de", Kind: TLDCountry, Country: "Germany"},
+		"рф":       {TLD: "xn--p1ai", Kind: TLDCountry, Country: "Russia"},
+		"xn--p1ai": {TLD: "xn--p1ai", Kind: TLDCountry, Country: "Russia"},
+		"ישראל":    {TLD: "xn--4dbrk0ce", Kind: TLDCountry, Country: "Israel"},
+		"bv":       {TLD: "bv", Kind: TLDCountry, Country: "Bouvet Island"},
+		"cloud":    {TLD: "cloud", Kind: TLDGeneric},
+		"email":    {TLD: "email", Kind: TLDGeneric},
+		"agency":   {TLD: "agency", Kind: TLDGeneric},
+		"онлайн":   {TLD: "xn--80asehdb", Kind: TLDGeneric},
+		"invalid":  {TLD: "invalid", Kind: TLDUnknown},
+	} {
+		if info := LookupTLD(tld); info != wants {
+			t.Errorf("TLD info mismatch for %q: wanted %+v ; got %+v", tld, wants, info)
+		}
+	}
+}
+
+func TestTLDReport(t *testing.T) {
+	report := NewTLDReport([]Entry{
+		{Count: 5, Domain: "example.com"},
+		{Count: 3, Domain: "other.com"},
+		{Count: 4, Domain: "bbc.co.uk"},
+		{Count: 2, Domain: "royal.gb"},
+		{Count: 4, Domain: "harvard.edu"},
+		{Count: 1, Domain: "пример.рф"},
+		{Count: 1, Domain: "example.invalid"},
+	})
+
+	if report.Total != 20 {
+		t.Errorf("total mismatch: wanted %d ; got %d", 20, report.Total)
+	}
+
+	wantsTLDs := []TLDEntry{
+		{TLDInfo: TLDInfo{TLD: "com", Kind: TLDGeneric}, Domains: 2, Count: 8, Share: 40},
+		{TLDInfo: TLDInfo{TLD: "edu", Kind: TLDSponsored}, Domains: 1, Count: 4, Share: 20},
+		{TLDInfo: TLDInfo{TLD: "uk", Kind: TLDCountry, Country: "United Kingdom"}, Domains: 1, Count: 4, Share: 20},
+		{TLDInfo: TLDInfo{TLD: "gb", Kind: TLDCountry, Country: "United Kingdom"}, Domains: 1, Count: 2, Share: 10},
+		{TLDInfo: TLDInfo{TLD: "invalid", Kind: TLDUnknown}, Domains: 1, Count: 1, Share: 5},
+		{TLDInfo: TLDInfo{TLD: "xn--p1ai", Kind: TLDCountry, Country: "Russia"}, Domains: 1, Count: 1, Share: 5},
+	}
+	if len(report.TLDs) != len(wantsTLDs) {
+		t.Errorf("TLD count mismatch: wanted %d ; got %d: %v", len(wantsTLDs), len(report.TLDs), report.TLDs)
+		return
+	}
+	for idx := range wantsTLDs {
+		if report.TLDs[idx] != wantsTLDs[idx] {
+			t.Errorf("TLD entry #%d mismatch: wanted %+v ; got %+v", idx, wantsTLDs[idx], report.TLDs[idx])
+		}
+	}
+
+	if len(report.Countries) != 2 {
+		t.Errorf("country count mismatch: wanted %d ; got %d: %v", 2, len(report.Countries), report.Countries)
+		return
+	}
+	if uk := report.Countries[0]; uk.Country != "United Kingdom" || uk.Count != 6 || strings.Join(uk.TLDs, ",") != "gb,uk" {
+		t.Errorf("country mismatch: wanted United Kingdom with 6 users in gb,uk ; got %+v", uk)
+	}
+
+	for kind, wants := range map[TLDKind]int{TLDGeneric: 8, TLDSponsored: 4, TLDCountry: 7, TLDUnknown: 1} {
+		if report.Kinds[kind] != wants {
+			t.Errorf("kind count mismatch for %v: wanted %d ; got %d", kind, wants, report.Kinds[kind])
+		}
+	}
+
+	t.Run("Encode", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := report.EncodeCountries(buf, FormatCSV); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		wants := "country,tlds,count,share\nUnited Kingdom,gb uk,6,30.0000\nRussia,xn--p1ai,1,5.0000\n"
+		if buf.String() != wants {
+			t.Errorf("output mismatch error: wanted %q ; got %q", wants, buf.String())
+		}
+	})
+
+	t.Run("FromResult", func(t *testing.T) {
+		res, err := Count(rawPath)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		report := res.TLDReport()
+		if report.Total != 3000 || report.TLDs[0].TLD != "com" {
+			t.Errorf("report mismatch: wanted 3000 users with com first ; got %d with %v", report.Total, report.TLDs[0])
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package customerimporter
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+)
+
+// HeavyHitter is an item tracked by HeavyHitters, with its estimated count
+type HeavyHitter struct {
+	Item string `json:"item"`
+	// Count is an upper bound for the item's count
+	Count int `json:"count"`
+	// Error is how much Count may exceed the item's true count
+	Error int `json:"error"`
+	// Guaranteed reports whether the item is certainly among the returned top items, since
+	// its lower bound (Count - Error) is not below the count of any item left out
+	Guaranteed bool `json:"guaranteed"`
+}
+
+// HeavyHitters finds the most frequent items in a stream with a fixed number of counters,
+// using the Space-Saving algorithm: once all counters are in use, a new item replaces the
+// item with the lowest count, inheriting its count as the error.
+//
+// Every item seen more than Total / capacity times is tracked, and each tracked count
+// exceeds the true count by at most Total / capacity. A HeavyHitters is not safe for
+// concurrent use
+type HeavyHitters struct {
+	capacity int
+	total    int
+	evicted  bool
+	index    map[string]*hitter
+	heap     hitterHeap
+}
+
+// hitter is a counter in HeavyHitters, positioned in a min-heap by count
+type hitter struct {
+	item  string
+	count int
+	err   int
+	pos   int
+}
+
+// hitterHeap is a min-heap of counters, implementing heap.Interface
+type hitterHeap []*hitter
+
+func (h hitterHeap) Len() int { return len(h) }
+
+func (h hitterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h hitterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *hitterHeap) Push(x any) {
+	c := x.(*hitter)
+	c.pos = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *hitterHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// NewHeavyHitters creates a HeavyHitters with `capacity` counters (at least 1). Memory usage
+// is bound by the capacity, and so is the accuracy: a larger capacity lowers the error bound
+func NewHeavyHitters(capacity int) *HeavyHitters {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &HeavyHitters{
+		capacity: capacity,
+		index:    make(map[string]*hitter, capacity),
+		heap:     make(hitterHeap, 0, capacity),
+	}
+}
+
+// Add counts one occurrence of `item`
+func (h *HeavyHitters) Add(item string) {
+	h.add(item, 1)
+}
+
+// add counts `n` occurrences of `item`, returning the item it replaced, if any
+func (h *HeavyHitters) add(item string, n int) (evicted string, ok bool) {
+	h.total += n
+
+	if c, ok := h.index[item]; ok {
+		c.count += n
+		heap.Fix(&h.heap, c.pos)
+		return "", false
+	}
+
+	// copy the key so the counters do not pin the input's backing strings
+	item = strings.Clone(item)
+
+	if len(h.heap) < h.capacity {
+		c := &hitter{item: item, count: n}
+		h.index[item] = c
+		heap.Push(&h.heap, c)
+		return "", false
+	}
+
+	// replace the item with the lowest count, which bounds the new item's error
+	c := h.heap[0]
+	evicted = c.item
+	delete(h.index, c.item)
+	h.evicted = true
+
+	c.item = item
+	c.err = c.count
+	c.count += n
+	h.index[item] = c
+	heap.Fix(&h.heap, 0)
+
+	return evicted, true
+}
+
+// Total returns the number of occurrences counted, for all items
+func (h *HeavyHitters) Total() int {
+	return h.total
+}
+
+// Len returns the number of tracked items
+func (h *HeavyHitters) Len() int {
+	return len(h.heap)
+}
+
+// ErrorBound returns the maximum error for any tracked item, which is also the maximum count
+// for any item that is not tracked: the lowest tracked count once items have been replaced
+func (h *HeavyHitters) ErrorBound() int {
+	if !h.evicted {
+		return 0
+	}
+	return h.heap[0].count
+}
+
+// Top returns up to `k` of the tracked items with the highest counts, by descending count
+// and item. A negative `k` returns all tracked items
+func (h *HeavyHitters) Top(k int) []HeavyHitter {
+	hitters, _ := h.top(k)
+	return hitters
+}
+
+// top returns up to `k` of the tracked items with the highest counts, along with the maximum
+// count for any item left out of them
+func (h *HeavyHitters) top(k int) ([]HeavyHitter, int) {
+	hitters := make([]HeavyHitter, len(h.heap))
+	for idx, c := range h.heap {
+		hitters[idx] = HeavyHitter{Item: c.item, Count: c.count, Error: c.err}
+	}
+
+	sort.Slice(hitters, func(i, j int) bool {
+		if hitters[i].Count != hitters[j].Count {
+			return hitters[i].Count > hitters[j].Count
+		}
+		return hitters[i].Item < hitters[j].Item
+	})
+
+	// the items left out have a count up to the next tracked count, or to the error bound
+	next := h.ErrorBound()
+	if k < 0 || k > len(hitters) {
+		k = len(hitters)
+	}
+	if k < len(hitters) && hitters[k].Count > next {
+		next = hitters[k].Count
+	}
+
+	hitters = hitters[:k]
+	for idx := range hitters {
+		hitters[idx].Guaranteed = hitters[idx].Count-hitters[idx].Error >= next
+	}
+
+	return hitters, next
+}
@@ -0,0 +1,252 @@
+package customerimporter_test
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestHeavyHitters(t *testing.T) {
+	t.Run("Exact", func(t *testing.T) {
+		h := NewHeavyHitters(5)
+		for _, item := range strings.Fields("a b a c a b d a b c") {
+			h.Add(item)
+		}
+
+		wants := []HeavyHitter{
+			{Item: "a", Count: 4, Guaranteed: true},
+			{Item: "b", Count: 3, Guaranteed: true},
+			{Item: "c", Count: 2, Guaranteed: true},
+		}
+		top := h.Top(3)
+		if len(top) != len(wants) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d", len(wants), len(top))
+			return
+		}
+		for idx := range wants {
+			if top[idx] != wants[idx] {
+				t.Errorf("output mismatch error on index %d: wanted %+v ; got %+v", idx, wants[idx], top[idx])
+			}
+		}
+
+		if h.Total() != 10 || h.Len() != 4 || h.ErrorBound() != 0 {
+			t.Errorf("output mismatch error: wanted 10 items, 4 tracked and no error ; got %d, %d and %d", h.Total(), h.Len(), h.ErrorBound())
+		}
+		if all := h.Top(-1); len(all) != 4 {
+			t.Errorf("output length mismatch error: wanted %d ; got %d", 4, len(all))
+		}
+	})
+
+	t.Run("Bounded", func(t *testing.T) {
+		const capacity = 20
+
+		var (
+			h     = NewHeavyHitters(capacity)
+			exact = map[string]int{}
+			rng   = rand.New(rand.NewSource(1))
+		)
+
+		// a few heavy items within a long tail of rare ones
+		for i := 0; i < 20000; i++ {
+			item := "rare" + strconv.Itoa(rng.Intn(5000))
+			switch n := rng.Intn(100); {
+			case n < 15:
+				item = "heavy1"
+			case n < 25:
+				item = "heavy2"
+			case n < 30:
+				item = "heavy3"
+			}
+
+			exact[item]++
+			h.Add(item)
+		}
+
+		if h.Len() != capacity {
+			t.Errorf("expected the counters to be bound by the capacity: wanted %d ; got %d", capacity, h.Len())
+		}
+		if bound := h.Total() / capacity; h.ErrorBound() > bound {
+			t.Errorf("error bound above Total / capacity: %d > %d", h.ErrorBound(), bound)
+		}
+
+		top := h.Top(3)
+		for idx, item := range []string{"heavy1", "heavy2", "heavy3"} {
+			if top[idx].Item != item || !top[idx].Guaranteed {
+				t.Errorf("output mismatch error on index %d: wanted guaranteed %q ; got %+v", idx, item, top[idx])
+			}
+		}
+
+		for _, hitter := range h.Top(-1) {
+			count := exact[hitter.Item]
+			if hitter.Count < count || hitter.Count-hitter.Error > count || hitter.Error > h.ErrorBound() {
+				t.Errorf("bounds mismatch error for %q: true count %d ; got %+v (bound %d)", hitter.Item, count, hitter, h.ErrorBound())
+			}
+		}
+	})
+}
+
+func TestTopK(t *testing.T) {
+	t.Run("Bounded", func(t *testing.T) {
+		var sb strings.Builder
+		sb.WriteString("email\n")
+		for i := 0; i < 2000; i++ {
+			domain := "tail" + strconv.Itoa(i) + ".com"
+			switch {
+			case i%4 == 0:
+				domain = "gmail.com"
+			case i%10 == 1:
+				domain = "yahoo.com"
+			}
+			sb.WriteString("user" + strconv.Itoa(i) + "@" + domain + "\n")
+		}
+
+		res, err := CountReader(strings.NewReader(sb.String()), WithTopK(2, 50), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if len(res.Entries) != 2 || res.Entries[0].Domain != "gmail.com" || res.Entries[1].Domain != "yahoo.com" {
+			t.Errorf("output mismatch error: wanted gmail.com and yahoo.com ; got %v", res.Entries)
+			return
+		}
+		for domain, count := range map[string]int{"gmail.com": 500, "yahoo.com": 200} {
+			e, _ := res.Lookup(domain)
+			if e.Count < count || e.Count-e.Error > count {
+				t.Errorf("bounds mismatch error for %q: true count %d ; got %d with error %d", domain, count, e.Count, e.Error)
+			}
+		}
+
+		if res.Valid != 2000 || res.ErrorBound == 0 || res.ErrorBound > res.Valid/50 {
+			t.Errorf("expected an error bound up to %d over %d rows ; got %d over %d", 2000/50, 2000, res.ErrorBound, res.Valid)
+		}
+	})
+
+	t.Run("ErrorColumn", func(t *testing.T) {
+		// far fewer counters than distinct domains overestimate the counts
+		res, err := Count(rawPath, WithTopK(3, 30), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		for _, test := range []struct {
+			format Format
+			header string
+		}{
+			{FormatCSV, "domain,count,error,share"},
+			{FormatTSV, "domain\tcount\terror\tshare"},
+			{FormatMarkdown, "| domain | count | error | share |"},
+		} {
+			var out strings.Builder
+			if err = res.Encode(&out, test.format); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if header, _, _ := strings.Cut(out.String(), "\n"); header != test.header {
+				t.Errorf("output mismatch error for %v: wanted header %q ; got %q", test.format, test.header, header)
+			}
+		}
+	})
+
+	t.Run("DefaultCapacity", func(t *testing.T) {
+		exact, err := Parse(rawPath, WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		// the default capacity fits the distinct domains in the test data, keeping counts exact
+		res, err := Count(rawPath, WithTopK(3, 0), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		for idx, e := range res.Entries {
+			if e.Count != exact[idx].Count || e.Error != 0 {
+				t.Errorf("output mismatch error on index %d: wanted %v ; got %v", idx, exact[idx], e)
+			}
+		}
+	})
+
+	t.Run("ApproximateDedup", func(t *testing.T) {
+		var sb strings.Builder
+		sb.WriteString("email\n")
+		for i := 0; i < 2000; i++ {
+			domain := "tail" + strconv.Itoa(i) + ".com"
+			if i%4 == 0 {
+				domain = "gmail.com"
+			}
+			sb.WriteString("user" + strconv.Itoa(i) + "@" + domain + "\n")
+		}
+
+		res, err := CountReader(strings.NewReader(sb.String()), WithTopK(1, 10), WithApproximateDedup(0.01))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		// the sketches of the domains left out are dropped along with their counters
+		if len(res.DomainSketches) != 1 || res.DomainSketches["gmail.com"] == nil {
+			t.Errorf("output mismatch error: wanted a sketch for gmail.com only ; got %d sketches", len(res.DomainSketches))
+		}
+		if e, _ := res.Lookup("gmail.com"); e.Unique < 490 || e.Unique > 510 {
+			t.Errorf("estimate out of bounds for gmail.com: wanted about %d ; got %d", 500, e.Unique)
+		}
+	})
+
+	t.Run("Customers", func(t *testing.T) {
+		exact, err := Parse(rawPath, WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		// with as many counters as distinct domains, the counts are exact
+		res, err := Count(rawPath, WithTopK(10, len(exact)), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		// the domains left out have up to the count of the 11th domain
+		if len(res.Entries) != 10 || res.ErrorBound != exact[10].Count {
+			t.Errorf("output mismatch error: wanted 10 exact entries with error bound %d ; got %d with error bound %d",
+				exact[10].Count, len(res.Entries), res.ErrorBound)
+			return
+		}
+		for idx, e := range res.Entries {
+			if e.Domain != exact[idx].Domain || e.Count != exact[idx].Count || e.Error != 0 {
+				t.Errorf("output mismatch error on index %d: wanted %v ; got %v", idx, exact[idx], e)
+			}
+		}
+	})
+
+	t.Run("LeftOut", func(t *testing.T) {
+		// no domain is evicted, so the counts are exact, but a domain with 98 rows is left out
+		var sb strings.Builder
+		sb.WriteString("email\n")
+		for n := 100; n > 90; n-- {
+			for i := 0; i < n; i++ {
+				sb.WriteString("user@domain" + strconv.Itoa(n) + ".com\n")
+			}
+		}
+
+		res, err := CountReader(strings.NewReader(sb.String()), WithTopK(2, 0), WithSort(SortByCountDesc))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if len(res.Entries) != 2 || res.ErrorBound != 98 {
+			t.Errorf("output mismatch error: wanted 2 entries with error bound 98 ; got %d with error bound %d", len(res.Entries), res.ErrorBound)
+		}
+		// duplicates are not tracked, to keep the memory usage bounded
+		if res.Duplicates != 0 {
+			t.Errorf("output mismatch error: wanted no duplicates ; got %d", res.Duplicates)
+		}
+	})
+}
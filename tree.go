@@ -0,0 +1,188 @@
+package customerimporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrSkipChildren can be returned by the function passed to Node.Walk to skip the children
+// of the current Node
+var ErrSkipChildren = errors.New("skip children")
+
+// Node is a label in the domain tree built by BuildTree, such as `co` in `co.uk`. The
+// root Node has no label, and holds the top-level domains as its children
+type Node struct {
+	// Label is the leftmost label of the node's domain, like `co` for `co.uk`
+	Label string `json:"label"`
+	// Domain is the full domain name for the node, like `co.uk`
+	Domain string `json:"domain"`
+	// Count is the number of addresses in this exact domain
+	Count int `json:"count"`
+	// Total is the number of addresses in this domain and all of its subdomains
+	Total int `json:"total"`
+	// Children lists the subdomains one label down, sorted by label
+	Children []*Node `json:"children,omitempty"`
+
+	index map[string]*Node
+}
+
+// BuildTree organizes the domain counts in `domains` (as produced by the domain counter) in a
+// label tree, such as `uk` → `co.uk` → `123-reg.co.uk`, with the subtotals for each node.
+// Returns the root Node
+func BuildTree(domains map[string]int) *Node {
+	root := &Node{}
+
+	for domain, count := range domains {
+		node := root
+		root.Total += count
+
+		// walk the labels from right to left
+		for end := len(domain); end > 0; {
+			start := strings.LastIndexByte(domain[:end], '.') + 1
+
+			node = node.child(domain[start:end], domain[start:])
+			node.Total += count
+
+			end = start - 1
+		}
+
+		node.Count += count
+	}
+
+	root.sort()
+
+	return root
+}
+
+// Tree returns the domain tree for the Result's entries (see BuildTree). When rolled up by
+// registrable domain, the tree is built from the original domains in each Entry's Children
+func (r *Result) Tree() *Node {
+	domains := make(map[string]int, len(r.Entries))
+
+	for _, e := range r.Entries {
+		if len(e.Children) == 0 {
+			domains[e.Domain] += e.Count
+			continue
+		}
+
+		for _, child := range e.Children {
+			domains[child.Domain] += child.Count
+		}
+	}
+
+	return BuildTree(domains)
+}
+
+// child returns the child Node with `label`, creating it if it does not exist
+func (n *Node) child(label, domain string) *Node {
+	if n.index == nil {
+		n.index = map[string]*Node{}
+	}
+	if c, ok := n.index[label]; ok {
+		return c
+	}
+
+	c := &Node{Label: label, Domain: domain}
+	n.Children = append(n.Children, c)
+	n.index[label] = c
+	return c
+}
+
+// lookup returns the child Node with `label`, if any
+func (n *Node) lookup(label string) *Node {
+	if n.index != nil {
+		return n.index[label]
+	}
+
+	for _, c := range n.Children {
+		if c.Label == label {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Label < n.Children[j].Label
+	})
+
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// Find returns the Node for `domain` (such as `edu` or `co.uk`), and whether it is in the tree
+func (n *Node) Find(domain string) (*Node, bool) {
+	node := n
+
+	for end := len(domain); end > 0; {
+		start := strings.LastIndexByte(domain[:end], '.') + 1
+		label := domain[start:end]
+
+		next := node.lookup(label)
+		if next == nil {
+			return nil, false
+		}
+
+		node = next
+		end = start - 1
+	}
+
+	return node, node != n || domain == ""
+}
+
+// Walk calls `fn` for the Node and each of its descendants, depth-first and in label order,
+// with the depth relative to the Node. If `fn` returns ErrSkipChildren, the children of that
+// Node are skipped; any other error stops the walk and is returned
+func (n *Node) Walk(fn func(node *Node, depth int) error) error {
+	err := n.walk(fn, 0)
+	if errors.Is(err, ErrSkipChildren) {
+		return nil
+	}
+	return err
+}
+
+func (n *Node) walk(fn func(node *Node, depth int) error, depth int) error {
+	if err := fn(n, depth); err != nil {
+		return err
+	}
+
+	for _, c := range n.Children {
+		if err := c.walk(fn, depth+1); err != nil && !errors.Is(err, ErrSkipChildren) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fprint writes the tree under the Node to `w`, one domain per line, indented by depth and
+// with its subtotal. Domains with addresses of their own and subdomains also show their own count
+func (n *Node) Fprint(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	err := n.Walk(func(node *Node, depth int) error {
+		name := node.Domain
+		if name == "" {
+			name = "."
+		}
+
+		var err error
+		if node.Count > 0 && len(node.Children) > 0 {
+			_, err = fmt.Fprintf(bw, "%s%s: %d (%d direct)\n", strings.Repeat("  ", depth), name, node.Total, node.Count)
+		} else {
+			_, err = fmt.Fprintf(bw, "%s%s: %d\n", strings.Repeat("  ", depth), name, node.Total)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,144 @@
+package customerimporter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestTree(t *testing.T) {
+	root := BuildTree(map[string]int{
+		"123-reg.co.uk":      8,
+		"mail.123-reg.co.uk": 2,
+		"bbc.co.uk":          10,
+		"gov.uk":             4,
+		"harvard.edu":        8,
+		"mit.edu":            9,
+	})
+
+	t.Run("Totals", func(t *testing.T) {
+		for domain, wants := range map[string][2]int{
+			"":                   {0, 41},
+			"uk":                 {0, 24},
+			"co.uk":              {0, 20},
+			"123-reg.co.uk":      {8, 10},
+			"mail.123-reg.co.uk": {2, 2},
+			"gov.uk":             {4, 4},
+			"edu":                {0, 17},
+		} {
+			node, ok := root.Find(domain)
+			if !ok {
+				t.Errorf("expected to find %q in the tree", domain)
+				continue
+			}
+			if node.Count != wants[0] || node.Total != wants[1] {
+				t.Errorf("node mismatch for %q: wanted count %d and total %d ; got %d and %d",
+					domain, wants[0], wants[1], node.Count, node.Total)
+			}
+		}
+
+		if _, ok := root.Find("gov"); ok {
+			t.Error("expected not to find gov in the tree")
+		}
+	})
+
+	t.Run("Walk", func(t *testing.T) {
+		var visited []string
+		err := root.Walk(func(node *Node, depth int) error {
+			visited = append(visited, node.Domain)
+			if node.Domain == "co.uk" {
+				return ErrSkipChildren
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		wants := []string{"", "edu", "harvard.edu", "mit.edu", "uk", "co.uk", "gov.uk"}
+		if len(visited) != len(wants) {
+			t.Errorf("walk mismatch: wanted %v ; got %v", wants, visited)
+			return
+		}
+		for idx := range wants {
+			if visited[idx] != wants[idx] {
+				t.Errorf("walk mismatch: wanted %v ; got %v", wants, visited)
+				return
+			}
+		}
+
+		stop := errors.New("stop")
+		if err := root.Walk(func(*Node, int) error { return stop }); !errors.Is(err, stop) {
+			t.Errorf("unexpected error: wanted %v ; got %v", stop, err)
+		}
+	})
+
+	t.Run("Fprint", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := root.Fprint(buf); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		wants := `.: 41
+  edu: 17
+    harvard.edu: 8
+    mit.edu: 9
+  uk: 24
+    co.uk: 20
+      123-reg.co.uk: 10 (8 direct)
+        mail.123-reg.co.uk: 2
+      bbc.co.uk: 10
+    gov.uk: 4
+`
+		if buf.String() != wants {
+			t.Errorf("output mismatch error: wanted %q ; got %q", wants, buf.String())
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(root)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		decoded := &Node{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		node, ok := decoded.Find("co.uk")
+		if !ok || node.Total != 20 || len(node.Children) != 2 {
+			t.Errorf("decoded tree mismatch: got %v", node)
+		}
+	})
+
+	t.Run("FromResult", func(t *testing.T) {
+		res, err := Count(rawPath, WithRollup(nil))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		tree := res.Tree()
+		if tree.Total != 3000 {
+			t.Errorf("total mismatch: wanted %d ; got %d", 3000, tree.Total)
+		}
+
+		var edu int
+		for domain, count := range expectedResults {
+			if len(domain) > 4 && domain[len(domain)-4:] == ".edu" {
+				edu += count
+			}
+		}
+		if node, ok := tree.Find("edu"); !ok || node.Total != edu {
+			t.Errorf("edu total mismatch: wanted %d ; got %v", edu, node)
+		}
+	})
+}
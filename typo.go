@@ -0,0 +1,256 @@
+package customerimporter
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// typoMaxDistance is the maximum weighted edit distance between a typo and its correction
+	typoMaxDistance = 1.5
+	// typoMinConfidence is the minimum confidence for a domain to be reported as a typo
+	typoMinConfidence = 0.85
+	// typoRatio is how many times larger the count of a parsed domain must be than the count
+	// of a typo, for the domain to be suggested as its correction
+	typoRatio = 10
+
+	// adjacentCost is the cost of substituting a character for a neighbouring key, or of
+	// transposing two characters, which are the most frequent typing mistakes
+	adjacentCost = 0.5
+)
+
+// keyboardRows lists the rows in a QWERTY keyboard, each one offset by about half a key from the previous one
+var keyboardRows = []string{"1234567890-", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// keyboardAdjacency maps each key to the keys around it
+var keyboardAdjacency = buildKeyboardAdjacency(keyboardRows)
+
+func buildKeyboardAdjacency(rows []string) map[byte]string {
+	adjacency := map[byte]string{}
+	link := func(a, b byte) {
+		adjacency[a] += string(b)
+		adjacency[b] += string(a)
+	}
+
+	for r, row := range rows {
+		for i := 0; i < len(row); i++ {
+			if i+1 < len(row) {
+				link(row[i], row[i+1])
+			}
+
+			// the key at index i sits between keys i-1 and i in the row below
+			if r+1 < len(rows) {
+				below := rows[r+1]
+				if i < len(below) {
+					link(row[i], below[i])
+				}
+				if i > 0 && i-1 < len(below) {
+					link(row[i], below[i-1])
+				}
+			}
+		}
+	}
+
+	return adjacency
+}
+
+// Typo is a domain that is likely a misspelling of another one, as found by a TypoDetector
+type Typo struct {
+	// Domain is the misspelled domain
+	Domain string `json:"domain"`
+	// Count is the number of addresses in the misspelled domain
+	Count int `json:"count"`
+	// Suggestion is the likely correct domain
+	Suggestion string `json:"suggestion"`
+	// Distance is the weighted edit distance between Domain and Suggestion
+	Distance float64 `json:"distance"`
+	// Confidence is a score from 0 to 1 on how likely Domain is a misspelling of Suggestion
+	Confidence float64 `json:"confidence"`
+}
+
+// TypoDetector finds domains that are likely misspellings of well-known domains, or of much
+// more frequent domains in the same data, such as `gmial.com` for `gmail.com`.
+//
+// Domains are compared with a weighted edit distance (optimal string alignment), where
+// substituting a character for a neighbouring key in a QWERTY keyboard, or transposing two
+// characters, costs half as much as any other edit
+type TypoDetector struct {
+	known *DomainList
+}
+
+// NewTypoDetector creates a TypoDetector with the reference domains in `known`. A nil `known`
+// uses the free-mail providers in DefaultClassifier
+func NewTypoDetector(known *DomainList) *TypoDetector {
+	if known == nil {
+		known = NewDomainList()
+		for domain, category := range DefaultClassifier().rules {
+			if category == CategoryFreeMail {
+				known.Add(domain)
+			}
+		}
+	}
+
+	return &TypoDetector{known: known}
+}
+
+// Suggest returns the known domain that `domain` is likely a misspelling of, along with its
+// confidence. Returns false if there is none, or if `domain` is a known domain itself
+func (d *TypoDetector) Suggest(domain string) (string, float64, bool) {
+	domain = canonicalDomain(domain)
+	if _, ok := d.known.domains[domain]; ok {
+		return "", 0, false
+	}
+
+	best, ok := d.closest(domain, 1, nil)
+	if !ok {
+		return "", 0, false
+	}
+
+	return best.Suggestion, best.Confidence, true
+}
+
+// Detect returns the likely typos in the domain counts `domains`, sorted by count (descending)
+// and domain. A domain is compared against the known domains, and against the domains with a
+// count at least 10 times larger than its own
+func (d *TypoDetector) Detect(domains map[string]int) []Typo {
+	// the frequent domains are the candidate corrections within the data, by descending count
+	frequent := make([]string, 0, len(domains))
+	for domain, count := range domains {
+		if count >= typoRatio {
+			frequent = append(frequent, domain)
+		}
+	}
+	sort.Slice(frequent, func(i, j int) bool {
+		if domains[frequent[i]] != domains[frequent[j]] {
+			return domains[frequent[i]] > domains[frequent[j]]
+		}
+		return frequent[i] < frequent[j]
+	})
+
+	var typos []Typo
+	for domain, count := range domains {
+		if _, ok := d.known.domains[domain]; ok {
+			continue
+		}
+
+		var candidates []string
+		for _, ref := range frequent {
+			if domains[ref] < count*typoRatio {
+				break
+			}
+			candidates = append(candidates, ref)
+		}
+
+		typo, ok := d.closest(domain, count, candidates)
+		if !ok {
+			continue
+		}
+
+		typos = append(typos, typo)
+	}
+
+	sort.Slice(typos, func(i, j int) bool {
+		if typos[i].Count != typos[j].Count {
+			return typos[i].Count > typos[j].Count
+		}
+		return typos[i].Domain < typos[j].Domain
+	})
+
+	return typos
+}
+
+// closest returns the Typo with the best correction for `domain`, out of the known domains
+// and `candidates`
+func (d *TypoDetector) closest(domain string, count int, candidates []string) (Typo, bool) {
+	var (
+		best  Typo
+		found bool
+	)
+
+	consider := func(ref string) {
+		if ref == domain || math.Abs(float64(len(ref)-len(domain))) > typoMaxDistance {
+			return
+		}
+
+		distance := typoDistance(domain, ref)
+		if distance > typoMaxDistance {
+			return
+		}
+
+		longest := len(ref)
+		if len(domain) > longest {
+			longest = len(domain)
+		}
+		confidence := 1 - distance/float64(longest)
+		if confidence < typoMinConfidence {
+			return
+		}
+
+		if !found || confidence > best.Confidence ||
+			(confidence == best.Confidence && ref < best.Suggestion) {
+			best = Typo{
+				Domain:     domain,
+				Count:      count,
+				Suggestion: ref,
+				Distance:   distance,
+				Confidence: math.Round(confidence*10000) / 10000,
+			}
+			found = true
+		}
+	}
+
+	for ref := range d.known.domains {
+		consider(ref)
+	}
+	for _, ref := range candidates {
+		consider(ref)
+	}
+
+	return best, found
+}
+
+// typoDistance returns the weighted optimal string alignment distance between `a` and `b`
+func typoDistance(a, b string) float64 {
+	// rows i-2, i-1 and i of the distance matrix
+	prev2 := make([]float64, len(b)+1)
+	prev := make([]float64, len(b)+1)
+	curr := make([]float64, len(b)+1)
+
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = float64(i)
+
+		for j := 1; j <= len(b); j++ {
+			cost := 0.0
+			if a[i-1] != b[j-1] {
+				cost = 1
+				if strings.IndexByte(keyboardAdjacency[a[i-1]], b[j-1]) >= 0 {
+					cost = adjacentCost
+				}
+			}
+
+			dist := prev[j-1] + cost
+			if del := prev[j] + 1; del < dist {
+				dist = del
+			}
+			if ins := curr[j-1] + 1; ins < dist {
+				dist = ins
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && a[i-1] != b[j-1] {
+				if swap := prev2[j-2] + adjacentCost; swap < dist {
+					dist = swap
+				}
+			}
+
+			curr[j] = dist
+		}
+
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,134 @@
+package customerimporter_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestTypoDetector(t *testing.T) {
+	d := NewTypoDetector(nil)
+
+	for _, testcase := range []struct {
+		domain     string
+		suggestion string
+		ok         bool
+	}{
+		// transposed characters
+		{domain: "gmial.com", suggestion: "gmail.com", ok: true},
+		// missing characters
+		{domain: "yaho.com", suggestion: "yahoo.com", ok: true},
+		{domain: "hotmal.com", suggestion: "hotmail.com", ok: true},
+		{domain: "gmail.co", suggestion: "gmail.com", ok: true},
+		// extra characters
+		{domain: "yahooo.com", suggestion: "yahoo.com", ok: true},
+		// neighbouring keys
+		{domain: "gmail.con", suggestion: "gmail.com", ok: true},
+		{domain: "hotmaul.com", suggestion: "hotmail.com", ok: true},
+		{domain: "Outlok.com", suggestion: "outlook.com", ok: true},
+		// known and unrelated domains
+		{domain: "gmail.com"},
+		{domain: "googlemail.com"},
+		{domain: "acme.com"},
+		{domain: "qq.cm"},
+		{domain: "gmx.com"},
+	} {
+		t.Run(testcase.domain, func(t *testing.T) {
+			suggestion, confidence, ok := d.Suggest(testcase.domain)
+			if ok != testcase.ok {
+				t.Errorf("output mismatch error: wanted %v ; got %v (%q)", testcase.ok, ok, suggestion)
+				return
+			}
+			if !ok {
+				return
+			}
+
+			if suggestion != testcase.suggestion {
+				t.Errorf("suggestion mismatch error: wanted %q ; got %q", testcase.suggestion, suggestion)
+			}
+			if confidence < 0.85 || confidence > 1 {
+				t.Errorf("confidence out of bounds: %v", confidence)
+			}
+		})
+	}
+
+	t.Run("NeighbouringKeysAreCloser", func(t *testing.T) {
+		_, adjacent, _ := d.Suggest("gmail.con")
+		_, other, _ := d.Suggest("gmail.cot")
+		if adjacent <= other {
+			t.Errorf("expected a neighbouring key typo to have a higher confidence: %v <= %v", adjacent, other)
+		}
+	})
+}
+
+func TestTypos(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("email\n")
+	for _, domain := range []string{"acmecorp.com", "gmail.com"} {
+		for i := 0; i < 20; i++ {
+			sb.WriteString("user@" + domain + "\n")
+		}
+	}
+	sb.WriteString("a@acmecrop.com\nb@gmial.com\nc@gmial.com\nd@yaho.com\ne@example.org\n")
+
+	t.Run("Detect", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(sb.String()), WithTypoDetection(nil))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		wants := []Typo{
+			{Domain: "gmial.com", Count: 2, Suggestion: "gmail.com"},
+			{Domain: "acmecrop.com", Count: 1, Suggestion: "acmecorp.com"},
+			{Domain: "yaho.com", Count: 1, Suggestion: "yahoo.com"},
+		}
+		if len(res.Typos) != len(wants) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d: %v", len(wants), len(res.Typos), res.Typos)
+			return
+		}
+		for idx := range wants {
+			typo := res.Typos[idx]
+			if typo.Domain != wants[idx].Domain || typo.Count != wants[idx].Count || typo.Suggestion != wants[idx].Suggestion {
+				t.Errorf("output mismatch error on index %d: wanted %v ; got %v", idx, wants[idx], typo)
+			}
+		}
+
+		if e, ok := res.Lookup("gmial.com"); !ok || e.Count != 2 {
+			t.Errorf("expected typos to be kept when not correcting them; got %v", e)
+		}
+	})
+
+	t.Run("Correct", func(t *testing.T) {
+		res, err := CountReader(strings.NewReader(sb.String()), WithTypoCorrection(nil))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if len(res.Typos) != 3 {
+			t.Errorf("output length mismatch error: wanted %d ; got %d: %v", 3, len(res.Typos), res.Typos)
+		}
+		for domain, wants := range map[string]int{
+			"gmail.com":    22,
+			"acmecorp.com": 21,
+			"yahoo.com":    1,
+			"example.org":  1,
+		} {
+			if e, ok := res.Lookup(domain); !ok || e.Count != wants {
+				t.Errorf("output mismatch error for %q: wanted %d ; got %v", domain, wants, e)
+			}
+		}
+		if _, ok := res.Lookup("gmial.com"); ok {
+			t.Errorf("expected gmial.com to be merged into gmail.com")
+		}
+
+		if e, _ := res.Lookup("gmail.com"); len(e.Aliases) != 1 || e.Aliases[0].Domain != "gmial.com" || e.Aliases[0].Count != 2 {
+			t.Errorf("expected the merged typo in the entry's aliases; got %v", e.Aliases)
+		}
+		if res.Total() != 45 {
+			t.Errorf("total mismatch error: wanted %d ; got %d", 45, res.Total())
+		}
+	})
+}
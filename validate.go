@@ -0,0 +1,201 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// maxAddressLen is the maximum length of an address in a SMTP path (RFC 5321, section 4.5.3.1.3)
+	maxAddressLen = 254
+	// maxLocalPartLen is the maximum length of a local-part (RFC 5321, section 4.5.3.1.1)
+	maxLocalPartLen = 64
+	// maxDomainLen is the maximum length of a domain name, without the trailing dot (RFC 1035)
+	maxDomainLen = 253
+	// maxLabelLen is the maximum length of a domain label (RFC 1035)
+	maxLabelLen = 63
+)
+
+var ErrInvalidLocalPart = errors.New("invalid local-part")
+
+// Address validation errors, with WithStrictValidation. Local-part errors wrap ErrInvalidLocalPart
+// and domain errors wrap ErrInvalidDomain
+var (
+	ErrAddressTooLong     = errors.New("address exceeds 254 characters")
+	ErrLocalPartEmpty     = fmt.Errorf("%w: empty local-part", ErrInvalidLocalPart)
+	ErrLocalPartTooLong   = fmt.Errorf("%w: local-part exceeds 64 characters", ErrInvalidLocalPart)
+	ErrLocalPartChar      = fmt.Errorf("%w: invalid character", ErrInvalidLocalPart)
+	ErrLocalPartDot       = fmt.Errorf("%w: leading, trailing or consecutive dots", ErrInvalidLocalPart)
+	ErrLocalPartQuote     = fmt.Errorf("%w: malformed quoted string", ErrInvalidLocalPart)
+	ErrDomainEmpty        = fmt.Errorf("%w: empty domain", ErrInvalidDomain)
+	ErrDomainTooLong      = fmt.Errorf("%w: domain exceeds 253 characters", ErrInvalidDomain)
+	ErrDomainLabelEmpty   = fmt.Errorf("%w: empty label", ErrInvalidDomain)
+	ErrDomainLabelTooLong = fmt.Errorf("%w: label exceeds 63 characters", ErrInvalidDomain)
+	ErrDomainLabelChar    = fmt.Errorf("%w: invalid character in label", ErrInvalidDomain)
+	ErrDomainLabelHyphen  = fmt.Errorf("%w: label starts or ends with a hyphen", ErrInvalidDomain)
+	ErrDomainLiteral      = fmt.Errorf("%w: invalid address literal", ErrInvalidDomain)
+)
+
+// ValidateAddress checks the syntax of an address split into its `local` part and `domain`
+// (see SplitAddress), following RFC 5322 and RFC 5321:
+//   - the local-part is either a dot-atom (`john.doe+tag`) or a quoted string (`"john doe"`);
+//     non-ASCII characters are allowed, as in RFC 6531
+//   - the domain is either a sequence of LDH labels (letters, digits and hyphens) or an
+//     address literal (`[192.0.2.1]`, `[IPv6:2001:db8::1]`); internationalized domains (such
+//     as `bücher.de`) are checked in their ASCII form (see ToASCII), as in RFC 6531
+//   - the address, local-part, domain and labels are within their length limits
+//
+// Returns nil if the address is valid, or the error describing the first failure found
+func ValidateAddress(local, domain string) error {
+	if err := validateLocalPart(local); err != nil {
+		return err
+	}
+	if !isASCII(domain) {
+		ascii, err := ToASCII(domain)
+		if err != nil {
+			return err
+		}
+		domain = ascii
+	}
+	if err := validateDomain(domain); err != nil {
+		return err
+	}
+	if len(local)+1+len(domain) > maxAddressLen {
+		return ErrAddressTooLong
+	}
+	return nil
+}
+
+func validateLocalPart(local string) error {
+	switch {
+	case local == "":
+		return ErrLocalPartEmpty
+	case len(local) > maxLocalPartLen:
+		return ErrLocalPartTooLong
+	case local[0] == '"':
+		return validateQuotedString(local)
+	}
+
+	for i := 0; i < len(local); i++ {
+		c := local[i]
+
+		if c == '.' {
+			if i == 0 || i == len(local)-1 || local[i-1] == '.' {
+				return ErrLocalPartDot
+			}
+			continue
+		}
+		if !isAtext(c) {
+			return ErrLocalPartChar
+		}
+	}
+
+	return nil
+}
+
+func validateQuotedString(local string) error {
+	if len(local) < 2 || local[len(local)-1] != '"' {
+		return ErrLocalPartQuote
+	}
+
+	inner := local[1 : len(local)-1]
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+
+		switch {
+		case c == '\\':
+			// quoted-pair: a backslash followed by a visible character or whitespace
+			if i == len(inner)-1 || (inner[i+1] < ' ' && inner[i+1] != '\t') || inner[i+1] == 0x7f {
+				return ErrLocalPartQuote
+			}
+			i++
+		case c == '"':
+			return ErrLocalPartQuote
+		case (c < ' ' && c != '\t') || c == 0x7f:
+			return ErrLocalPartChar
+		}
+	}
+
+	return nil
+}
+
+func validateDomain(domain string) error {
+	switch {
+	case domain == "":
+		return ErrDomainEmpty
+	case len(domain) > maxDomainLen:
+		return ErrDomainTooLong
+	case domain[0] == '[':
+		return validateDomainLiteral(domain)
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if err := validateLabel(label); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateLabel(label string) error {
+	switch {
+	case label == "":
+		return ErrDomainLabelEmpty
+	case len(label) > maxLabelLen:
+		return ErrDomainLabelTooLong
+	case label[0] == '-' || label[len(label)-1] == '-':
+		return ErrDomainLabelHyphen
+	}
+
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !isAlphaNum(c) && c != '-' {
+			return ErrDomainLabelChar
+		}
+	}
+
+	return nil
+}
+
+func validateDomainLiteral(domain string) error {
+	if len(domain) < 3 || domain[len(domain)-1] != ']' {
+		return ErrDomainLiteral
+	}
+
+	literal := domain[1 : len(domain)-1]
+	if v6, ok := cutPrefixFold(literal, "IPv6:"); ok {
+		if net.ParseIP(v6) == nil || !strings.Contains(v6, ":") {
+			return ErrDomainLiteral
+		}
+		return nil
+	}
+
+	if net.ParseIP(literal) == nil || strings.Contains(literal, ":") {
+		return ErrDomainLiteral
+	}
+	return nil
+}
+
+// cutPrefixFold returns `s` without the case-insensitive `prefix`, and whether it was present
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+func isAlphaNum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// isAtext reports whether `c` is allowed in a dot-atom (RFC 5322, section 3.2.3), extended
+// with the UTF-8 bytes allowed by RFC 6531
+func isAtext(c byte) bool {
+	if isAlphaNum(c) || c >= 0x80 {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
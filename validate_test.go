@@ -0,0 +1,119 @@
+package customerimporter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/zalgonoise/emailimp"
+)
+
+func TestValidateAddress(t *testing.T) {
+	for _, testcase := range []struct {
+		input string
+		err   error
+	}{
+		{input: "john@example.com"},
+		{input: "john.doe+tag@mail.example.co.uk"},
+		{input: "o'brien@example.ie"},
+		{input: `"john doe"@example.com`},
+		{input: `"john\"doe"@example.com`},
+		{input: `"weird@local"@example.com`},
+		{input: "jörg@example.de"},
+		{input: "user@bücher.de"},
+		{input: "user@пример.рф"},
+		{input: "user@bü_cher.de", err: ErrDomainLabelChar},
+		{input: "john@123-reg.co.uk"},
+		{input: "john@[192.0.2.1]"},
+		{input: "john@[IPv6:2001:db8::1]"},
+		{input: "a@", err: ErrDomainEmpty},
+		{input: "@b", err: ErrLocalPartEmpty},
+		{input: "x@@y", err: ErrLocalPartChar},
+		{input: "john doe@example.com", err: ErrLocalPartChar},
+		{input: ".john@example.com", err: ErrLocalPartDot},
+		{input: "john.@example.com", err: ErrLocalPartDot},
+		{input: "john..doe@example.com", err: ErrLocalPartDot},
+		{input: `"john"doe@example.com`, err: ErrLocalPartQuote},
+		{input: `"a"b"c"@example.com`, err: ErrLocalPartQuote},
+		{input: strings.Repeat("a", 65) + "@example.com", err: ErrLocalPartTooLong},
+		{input: "john@exam ple.com", err: ErrDomainLabelChar},
+		{input: "john@example..com", err: ErrDomainLabelEmpty},
+		{input: "john@.example.com", err: ErrDomainLabelEmpty},
+		{input: "john@-example.com", err: ErrDomainLabelHyphen},
+		{input: "john@example-.com", err: ErrDomainLabelHyphen},
+		{input: "john@example_mail.com", err: ErrDomainLabelChar},
+		{input: "john@" + strings.Repeat("a", 64) + ".com", err: ErrDomainLabelTooLong},
+		{input: "john@" + strings.Repeat("a.", 127) + "com", err: ErrDomainTooLong},
+		{input: strings.Repeat("a", 64) + "@" + strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63), err: ErrAddressTooLong},
+		{input: "john@[192.0.2.256]", err: ErrDomainLiteral},
+		{input: "john@[IPv6:192.0.2.1]", err: ErrDomainLiteral},
+		{input: "john@[192.0.2.1", err: ErrDomainLiteral},
+	} {
+		t.Run(testcase.input, func(t *testing.T) {
+			local, domain, err := SplitAddress(testcase.input, NormLastAt)
+			if err != nil {
+				t.Errorf("unexpected error splitting the address: %v", err)
+				return
+			}
+
+			err = ValidateAddress(local, domain)
+			if testcase.err == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, testcase.err) {
+				t.Errorf("unexpected error: wanted %v ; got %v", testcase.err, err)
+			}
+		})
+	}
+
+	t.Run("ErrorClasses", func(t *testing.T) {
+		if !errors.Is(ErrDomainLabelEmpty, ErrInvalidDomain) {
+			t.Errorf("expected %v to wrap %v", ErrDomainLabelEmpty, ErrInvalidDomain)
+		}
+		if !errors.Is(ErrLocalPartEmpty, ErrInvalidLocalPart) {
+			t.Errorf("expected %v to wrap %v", ErrLocalPartEmpty, ErrInvalidLocalPart)
+		}
+	})
+}
+
+func TestStrictValidation(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		entries, err := Parse(rawPath, WithStrictValidation())
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if len(entries) != len(expectedResults) {
+			t.Errorf("output length mismatch error: wanted %d ; got %d", len(expectedResults), len(entries))
+		}
+	})
+
+	t.Run("SkipInvalid", func(t *testing.T) {
+		// internationalized domains are valid without WithIDNA too
+		const input = `email
+john@example.com
+user@bücher.de
+a@
+@b
+x@@y
+jane@exam ple.com
+`
+		res, err := CountReader(strings.NewReader(input), WithStrictValidation(), WithErrorPolicy(SkipAndCount))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		if res.Valid != 2 || res.Invalid != 4 {
+			t.Errorf("totals mismatch: wanted 2 valid, 4 invalid ; got %d, %d", res.Valid, res.Invalid)
+		}
+		for _, reason := range []error{ErrDomainEmpty, ErrLocalPartEmpty, ErrLocalPartChar, ErrDomainLabelChar} {
+			if res.Skipped.Reasons[reason] != 1 {
+				t.Errorf("expected one row skipped with %v; got %v", reason, res.Skipped.Reasons)
+			}
+		}
+	})
+}